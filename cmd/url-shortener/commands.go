@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+	"url-shortener/internal/config"
+	"url-shortener/internal/lib/linkfile"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/cache"
+)
+
+const usage = `usage: url-shortener [command]
+
+Without a command the HTTP server is started.
+
+commands:
+  migrate up      apply all pending migrations
+  migrate down    roll back the latest applied migration
+  migrate status  list migrations and whether they are applied
+  export [--format csv|json|ndjson] [--output file]
+                  write all links to the file, or to stdout
+  import --file links.csv [--format csv|json|ndjson] [--on-conflict skip|overwrite|fail]
+                  save the links of the file in one transaction; the format
+                  defaults to the file extension, conflicts to fail`
+
+func runCommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(cfg, args[1:])
+	case "export":
+		return runExport(cfg, args[1:])
+	case "import":
+		return runImport(cfg, args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return nil
+	}
+
+	return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+}
+
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New(usage)
+	}
+
+	storage, err := setupStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+	m := storage.Migrator()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		mig, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %04d_%s\n", mig.Version, mig.Name)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", st.Version, st.Name, state)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q\n\n%s", args[0], usage)
+	}
+
+	return nil
+}
+
+// exportPageSize is the number of links read from storage at once.
+const exportPageSize = 1000
+
+func runExport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", linkfile.FormatCSV, "csv, json or ndjson")
+	output := flags.String("output", "", "file to write, stdout if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := setupStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	w, err := linkfile.NewWriter(out, *format)
+	if err != nil {
+		return err
+	}
+
+	n := 0
+	filter := storage.ListFilter{Sort: storage.SortCreatedAt, Limit: exportPageSize}
+	for {
+		urls, next, err := store.ListURLs(context.Background(), filter)
+		if err != nil {
+			return err
+		}
+		for _, u := range urls {
+			if err := w.Write(u); err != nil {
+				return err
+			}
+		}
+		n += len(urls)
+
+		if next == "" {
+			break
+		}
+		filter.Cursor = next
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if *output != "" {
+		if err := out.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("exported %d links to %s\n", n, *output)
+	}
+	return nil
+}
+
+func runImport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := flags.String("file", "", "file to read")
+	format := flags.String("format", "", "csv, json or ndjson, by file extension if empty")
+	onConflict := flags.String("on-conflict", storage.ConflictFail, "skip, overwrite or fail when an alias exists")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("import: --file is required")
+	}
+	if *format == "" {
+		*format = linkfile.FormatFromPath(*file)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	urls, err := linkfile.Read(f, *format)
+	if err != nil {
+		return fmt.Errorf("import %s: %w", *file, err)
+	}
+
+	store, err := setupStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if cfg.Cache.Redis.Address != "" {
+		//instances sharing the storage must stop serving the overwritten urls
+		redisClient, err := setupRedis(cfg.Cache.Redis)
+		if err != nil {
+			return err
+		}
+		defer redisClient.Close()
+
+		redisCache := cache.NewRedis(setupLogger(cfg.Env), redisClient, store, cache.RedisOptions{
+			KeyPrefix:   cfg.Cache.Redis.KeyPrefix,
+			TTL:         cfg.Cache.Redis.TTL,
+			NegativeTTL: cfg.Cache.Redis.NegativeTTL,
+		})
+		store = cache.NewStore(store, redisCache)
+	}
+
+	stats, err := store.ImportURLs(context.Background(), urls, *onConflict)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %d links: %d created, %d overwritten, %d skipped\n",
+		len(urls), stats.Created, stats.Overwritten, stats.Skipped)
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"url-shortener/internal/config"
+	"url-shortener/internal/storage/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImport_InvalidatesRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Env:           envLocal,
+		StorageDriver: driverSQLite,
+		StoragePath:   filepath.Join(dir, "storage.db"),
+		Cache: config.Cache{Redis: config.CacheRedis{
+			Address:   server.Addr(),
+			Timeout:   time.Second,
+			KeyPrefix: "test:",
+			TTL:       time.Hour,
+		}},
+	}
+	require.NoError(t, runMigrate(cfg, []string{"up"}))
+
+	importFile := func(url string) {
+		path := filepath.Join(dir, "links.csv")
+		require.NoError(t, os.WriteFile(path, []byte("alias,url\nex,"+url+"\n"), 0o600))
+		require.NoError(t, runImport(cfg, []string{"--file", path, "--on-conflict", "overwrite"}))
+	}
+	importFile("https://example.com/old")
+
+	//a replica serving the alias from the shared tier
+	store, err := setupStorage(cfg)
+	require.NoError(t, err)
+	defer store.Close()
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	replica := cache.NewRedis(slog.New(slog.DiscardHandler), client, store, cache.RedisOptions{
+		KeyPrefix: "test:",
+		TTL:       time.Hour,
+	})
+	ctx := context.Background()
+	got, err := replica.GetURL(ctx, "ex")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/old", got)
+
+	importFile("https://example.com/new")
+
+	got, err = replica.GetURL(ctx, "ex")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/new", got)
+}
@@ -1,75 +1,373 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
 	"url-shortener/internal/config"
+	"url-shortener/internal/lib/aliaspolicy"
+	"url-shortener/internal/lib/analytics"
+	"url-shortener/internal/lib/buildinfo"
+	"url-shortener/internal/lib/hashid"
+	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/reaper"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/cache"
+	"url-shortener/internal/storage/deadline"
+	"url-shortener/internal/storage/instrumented"
+	"url-shortener/internal/storage/postgres"
 	"url-shortener/internal/storage/sqlite"
 
 	my_slog "url-shortener/internal/lib/logger/my_slog"
 
 	"url-shortener/internal/http-server/handlers/redirect"
-	"url-shortener/internal/http-server/handlers/url/delete"
 	"url-shortener/internal/http-server/handlers/url/save"
-	mw_logger "url-shortener/internal/http-server/middleware/logger"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/http-server/router"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
 	_ = godotenv.Load("../../local.env")
 
 	cfg := config.MustLoad()
-	fmt.Println(cfg)
+
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	log := setupLogger(cfg.Env)
 
 	log.Info("Logger initialized", slog.String("env", cfg.Env))
 	log.Debug("logger debug")
 
-	storage, err := sqlite.New(cfg.StoragePath)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:       cfg.Tracing.Exporter,
+		Endpoint:       cfg.Tracing.Endpoint,
+		Insecure:       cfg.Tracing.Insecure,
+		SampleRatio:    cfg.Tracing.SampleRatio,
+		ServiceName:    cfg.Tracing.ServiceName,
+		ServiceVersion: buildinfo.Read().Version,
+	})
+	if err != nil {
+		log.Error("failed to init tracing", my_slog.Err(err))
+		os.Exit(1)
+	}
+
+	storage, err := setupStorage(cfg)
 	if err != nil {
 		log.Error("failed to init storage", my_slog.Err(err))
 		os.Exit(1)
 	}
 
-	_ = storage
+	if cfg.AutoMigrate {
+		applied, err := storage.Migrator().Up(context.Background())
+		if err != nil {
+			log.Error("failed to apply migrations", my_slog.Err(err))
+			os.Exit(1)
+		}
+		for _, m := range applied {
+			log.Info("applied migration", slog.Int("version", m.Version), slog.String("name", m.Name))
+		}
+	}
+	//once the migrations have created the tables the statements read
+	if p, ok := storage.(preparer); ok {
+		if err := p.Prepare(context.Background()); err != nil {
+			log.Warn("failed to prepare statements, they are prepared on first use", my_slog.Err(err))
+		}
+	}
+
+	storage = deadline.New(storage, deadline.Options{
+		Read:  cfg.StorageTimeout.Read,
+		Write: cfg.StorageTimeout.Write,
+	})
+	storage = instrumented.New(storage, cfg.StorageDriver)
+
+	//background writers stop after the http server, so clicks of drained requests are still flushed
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
 
-	//TODO: Init Router
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		reaper.Run(workersCtx, log, storage, cfg.ReaperInterval)
+	}()
+
+	clickRecorder := analytics.New(log, storage,
+		cfg.Analytics.BufferSize, cfg.Analytics.BatchSize, cfg.Analytics.FlushInterval)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		clickRecorder.Run(workersCtx)
+	}()
+
+	aliasStrategy := cfg.Alias.Strategy
+	var idEncoder save.IDEncoder
+	var urlGetter redirect.URLGetter = storage
+	var urlLookup cache.URLGetter = storage
+	if cfg.Alias.Strategy == aliasSequence {
+		codec, err := hashid.New(cfg.Alias.Salt, cfg.Alias.Length)
+		if err != nil {
+			log.Error("invalid alias config", my_slog.Err(err))
+			os.Exit(1)
+		}
+		idEncoder = codec
+		resolver := hashid.NewResolver(codec, storage)
+		urlGetter, urlLookup = resolver, resolver
+		// only a fallback for generated aliases, which the sequence strategy derives
+		// from ids; taken custom aliases are answered with 409 alias_taken
+		aliasStrategy = random.StrategyBase62
+	}
+
+	//shared tiers first, see cache.Store
+	var cacheTiers []cache.Invalidator
+	var redisCache *cache.Redis
+	var redisClient *redis.Client
+	if cfg.Cache.Redis.Address != "" {
+		redisClient, err = setupRedis(cfg.Cache.Redis)
+		if err != nil {
+			log.Error("failed to init redis cache", my_slog.Err(err))
+			os.Exit(1)
+		}
+
+		redisCache = cache.NewRedis(log, redisClient, urlLookup, cache.RedisOptions{
+			KeyPrefix:   cfg.Cache.Redis.KeyPrefix,
+			TTL:         cfg.Cache.Redis.TTL,
+			NegativeTTL: cfg.Cache.Redis.NegativeTTL,
+		})
+		urlGetter, urlLookup = redisCache, redisCache
+		cacheTiers = append(cacheTiers, redisCache)
+	}
+	if cfg.Cache.Size > 0 {
+		memoryCache := cache.New(urlLookup, cache.Options{
+			Size:        cfg.Cache.Size,
+			TTL:         cfg.Cache.TTL,
+			NegativeTTL: cfg.Cache.NegativeTTL,
+		})
+		urlGetter = memoryCache
+		cacheTiers = append(cacheTiers, memoryCache)
+
+		if redisCache != nil {
+			//writes of the other instances
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				redisCache.Subscribe(workersCtx, memoryCache)
+			}()
+		}
+	}
+	if len(cacheTiers) > 0 {
+		//handlers write through it to invalidate the aliases they change
+		storage = cache.NewStore(storage, cacheTiers...)
+	}
 
-	router := chi.NewRouter()
+	aliasGenerator, err := random.New(aliasStrategy, cfg.Alias.Length, cfg.Alias.Alphabet)
+	if err != nil {
+		log.Error("invalid alias config", my_slog.Err(err))
+		os.Exit(1)
+	}
 
-	router.Use(middleware.RequestID) //add id to all requests
-	router.Use(middleware.Logger)    //log all requests
-	router.Use(mw_logger.New(log))   //log all requests
-	router.Use(middleware.Recoverer) //recover from panics
-	router.Use(middleware.URLFormat) //parse url format
+	aliasPolicy, err := setupAliasPolicy(cfg.AliasPolicy)
+	if err != nil {
+		log.Error("invalid alias policy", my_slog.Err(err))
+		os.Exit(1)
+	}
 
-	router.Use(middleware.BasicAuth("url-shortener", map[string]string{
-		cfg.HTTPServer.User: cfg.HTTPServer.Password,
-	}))
+	redirectAuth, err := setupAuth(log, cfg.HTTPServer.RedirectAuth, storage, cfg)
+	if err != nil {
+		log.Error("invalid redirect_auth", my_slog.Err(err))
+		os.Exit(1)
+	}
+	managementAuth, err := setupAuth(log, cfg.HTTPServer.ManagementAuth, storage, cfg)
+	if err == nil && cfg.HTTPServer.ManagementAuth == authNone {
+		err = errors.New("management api cannot be public")
+	}
+	if err != nil {
+		log.Error("invalid management_auth", my_slog.Err(err))
+		os.Exit(1)
+	}
 
-	router.Post("/url", save.New(log, storage))
-	router.Get("/{alias}", redirect.New(log, storage))
-	router.Delete("/{alias}", delete.New(log, storage))
+	handler := router.New(router.Deps{
+		Log:            log,
+		Storage:        storage,
+		URLGetter:      urlGetter,
+		ClickRecorder:  clickRecorder,
+		AliasGenerator: aliasGenerator,
+		IDEncoder:      idEncoder,
+		AliasPolicy:    aliasPolicy,
+		RedirectAuth:   redirectAuth,
+		ManagementAuth: managementAuth,
+	})
 
 	log.Info("starting server", slog.String("address", cfg.Address))
 
 	srv := &http.Server{
 		Addr:         cfg.Address,
-		Handler:      router,
+		Handler:      handler,
 		ReadTimeout:  cfg.HTTPServer.Timeout,
 		WriteTimeout: cfg.HTTPServer.Timeout,
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
-	if err := srv.ListenAndServe(); err != nil {
-		log.Error("server failed to start", my_slog.Err(err))
-		os.Exit(1)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		log.Error("server failed", my_slog.Err(err))
+		exitCode = 1
+	case <-ctx.Done():
+		log.Info("shutting down", slog.Duration("timeout", cfg.HTTPServer.ShutdownTimeout))
+	}
+	//a second signal kills the process without waiting
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Error("failed to drain requests, closing remaining connections", my_slog.Err(err))
+		_ = srv.Close()
+		exitCode = 1
 	}
+
+	stopWorkers()
+	workers.Wait()
+
+	if redisClient != nil {
+		if err := redisClient.Close(); err != nil {
+			log.Error("failed to close redis cache", my_slog.Err(err))
+			exitCode = 1
+		}
+	}
+
+	if err := storage.Close(); err != nil {
+		log.Error("failed to close storage", my_slog.Err(err))
+		exitCode = 1
+	}
+
+	//spans of the final click flush included
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error("failed to flush spans", my_slog.Err(err))
+		exitCode = 1
+	}
+
+	log.Info("server stopped")
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+const (
+	driverSQLite   = "sqlite"
+	driverPostgres = "postgres"
+)
+
+func setupStorage(cfg *config.Config) (storage.Store, error) {
+	switch cfg.StorageDriver {
+	case driverSQLite:
+		return sqlite.New(cfg.StoragePath, sqlite.Options{
+			MaxOpenConns: cfg.SQLite.MaxOpenConns,
+			MaxIdleConns: cfg.SQLite.MaxIdleConns,
+			BusyTimeout:  cfg.SQLite.BusyTimeout,
+		})
+	case driverPostgres:
+		return postgres.New(cfg.StoragePath)
+	}
+
+	return nil, fmt.Errorf("unknown storage driver: %q", cfg.StorageDriver)
+}
+
+// preparer is a storage that prepares its statements ahead of their first use.
+type preparer interface {
+	Prepare(ctx context.Context) error
+}
+
+// setupRedis connects to the server of the shared cache tier.
+func setupRedis(cfg config.CacheRedis) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:         cfg.Address,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  cfg.Timeout,
+		ReadTimeout:  cfg.Timeout,
+		WriteTimeout: cfg.Timeout,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("ping %s: %w", cfg.Address, err)
+	}
+
+	return client, nil
+}
+
+// aliasSequence derives aliases from row ids instead of generating random ones.
+const aliasSequence = "sequence"
+
+// setupAliasPolicy builds the custom alias policy. The paths of the service are always reserved.
+func setupAliasPolicy(cfg config.AliasPolicy) (*aliaspolicy.Policy, error) {
+	opts := aliaspolicy.Options{
+		Charset:       cfg.Charset,
+		MinLength:     cfg.MinLength,
+		MaxLength:     cfg.MaxLength,
+		CaseSensitive: cfg.CaseSensitive,
+		Reserved:      append(slices.Clone(aliaspolicy.DefaultReserved), cfg.Reserved...),
+	}
+
+	if cfg.BlocklistFile != "" {
+		blocked, err := aliaspolicy.LoadBlocklist(cfg.BlocklistFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.Blocked = blocked
+	}
+
+	return aliaspolicy.New(opts)
+}
+
+const (
+	authNone   = "none"
+	authBasic  = "basic"
+	authAPIKey = "api_key"
+)
+
+// setupAuth returns the auth middleware of a route group for the given mode.
+func setupAuth(log *slog.Logger, mode string, keyGetter auth.APIKeyGetter, cfg *config.Config) (func(http.Handler) http.Handler, error) {
+	switch mode {
+	case authNone:
+		return func(next http.Handler) http.Handler { return next }, nil
+	case authBasic:
+		return auth.Basic(cfg.HTTPServer.User, cfg.HTTPServer.Password), nil
+	case authAPIKey:
+		//api keys as Bearer tokens; the config user/password stays valid as the admin BasicAuth credential
+		return auth.New(log, keyGetter, cfg.HTTPServer.User, cfg.HTTPServer.Password), nil
+	}
+
+	return nil, fmt.Errorf("unknown auth mode: %q", mode)
 }
 
 const (
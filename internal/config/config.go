@@ -10,9 +10,37 @@ import (
 
 type Config struct {
 	Env string `yaml:"env" env:"ENV" env-default:"local" env-required:"true"` //tag yaml for name in yaml file
+	//sqlite or postgres
+	StorageDriver string `yaml:"storage_driver" env:"STORAGE_DRIVER" env-default:"sqlite"`
 	//env-required - if env variable is missing, app will not start
-	StoragePath string `yaml:"storage_path" env-required:"true"`
-	HTTPServer `yaml:"http_server"`
+	StoragePath string `yaml:"storage_path" env-required:"true"` //sqlite file path, or connection string for postgres
+	//apply pending schema migrations on server start
+	AutoMigrate bool `yaml:"auto_migrate" env-default:"true"`
+	//how often expired links are purged from storage
+	ReaperInterval time.Duration `yaml:"reaper_interval" env-default:"1m"`
+	SQLite         `yaml:"sqlite"`
+	StorageTimeout `yaml:"storage_timeout"`
+	HTTPServer     `yaml:"http_server"`
+	Analytics      `yaml:"analytics"`
+	Alias          `yaml:"alias"`
+	AliasPolicy    `yaml:"alias_policy"`
+	Tracing        `yaml:"tracing"`
+	Cache          `yaml:"cache"`
+}
+
+// SQLite tunes the connections of the sqlite storage driver.
+type SQLite struct {
+	MaxOpenConns int           `yaml:"max_open_conns" env-default:"16"`
+	MaxIdleConns int           `yaml:"max_idle_conns" env-default:"16"`
+	BusyTimeout  time.Duration `yaml:"busy_timeout" env-default:"5s"` //how long a writer waits for another one
+}
+
+// StorageTimeout bounds every storage operation of the server, so that requests
+// waiting for a locked or unreachable database fail with 503 instead of piling up.
+// Keep them below http_server.timeout, or clients are cut off before the answer.
+type StorageTimeout struct {
+	Read  time.Duration `yaml:"read" env-default:"2s"`  //lookups, listings and stats; 0 is unbounded
+	Write time.Duration `yaml:"write" env-default:"3s"` //saves, updates and deletes; 0 is unbounded
 }
 
 type HTTPServer struct {
@@ -21,6 +49,69 @@ type HTTPServer struct {
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
 	User        string        `yaml:"user" env-required:"true"`
 	Password    string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
+	//how long in-flight requests may take to finish once SIGINT or SIGTERM is received
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"10s"`
+	//auth of GET /{alias}: none, basic or api_key
+	RedirectAuth string `yaml:"redirect_auth" env-default:"none"`
+	//auth of the /url and /admin management api: basic or api_key
+	ManagementAuth string `yaml:"management_auth" env-default:"api_key"`
+}
+
+// Analytics configures buffering of click events written by the redirect handler.
+type Analytics struct {
+	BufferSize    int           `yaml:"buffer_size" env-default:"10000"` //clicks are dropped when the buffer is full
+	BatchSize     int           `yaml:"batch_size" env-default:"500"`
+	FlushInterval time.Duration `yaml:"flush_interval" env-default:"1s"`
+}
+
+// Alias configures generation of aliases for urls saved without a custom one.
+type Alias struct {
+	Strategy string `yaml:"strategy" env-default:"base62"` //base62, human, words or sequence
+	Length   int    `yaml:"length" env-default:"8"`        //ignored by words, minimal length (up to 10) for sequence
+	Alphabet string `yaml:"alphabet"`                      //overrides the alphabet of base62 and human
+	//keys the encoding of sequence aliases; changing it breaks existing sequence aliases
+	Salt string `yaml:"salt" env:"ALIAS_SALT"`
+}
+
+// AliasPolicy restricts the custom aliases clients may choose.
+type AliasPolicy struct {
+	Charset       string   `yaml:"charset"` //allowed characters, empty for 0-9A-Za-z plus - and _
+	MinLength     int      `yaml:"min_length" env-default:"3"`
+	MaxLength     int      `yaml:"max_length" env-default:"64"`
+	CaseSensitive bool     `yaml:"case_sensitive" env-default:"false"` //whether reserved and blocked words match only in the exact case
+	Reserved      []string `yaml:"reserved"`                           //in addition to the paths of the service itself
+	BlocklistFile string   `yaml:"blocklist_file"`                     //words not allowed anywhere in an alias, one per line
+}
+
+// Tracing configures export of OpenTelemetry spans.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"` //none, otlp or stdout
+	Endpoint    string  `yaml:"endpoint"`                                           //host:port of the OTLP/HTTP collector; if empty OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318
+	Insecure    bool    `yaml:"insecure"`                                           //plain http to the collector
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`                       //share of new traces recorded; traces of callers keep their decision
+	ServiceName string  `yaml:"service_name" env:"OTEL_SERVICE_NAME" env-default:"url-shortener"`
+}
+
+// Cache configures the in-process cache of aliases resolved by redirects.
+// Writes of other instances are seen once entries go stale, unless they share Redis.
+type Cache struct {
+	Size        int           `yaml:"size" env-default:"10000"` //entries, 0 disables the cache
+	TTL         time.Duration `yaml:"ttl" env-default:"1m"`
+	NegativeTTL time.Duration `yaml:"negative_ttl" env-default:"5s"` //of unknown aliases, 0 disables caching them
+	Redis       CacheRedis    `yaml:"redis"`
+}
+
+// CacheRedis configures the cache tier in a Redis-compatible server, shared by all
+// instances between their in-process caches and the storage. Instances subscribed
+// to it drop the aliases written by any of them from their in-process caches.
+type CacheRedis struct {
+	Address     string        `yaml:"address" env:"REDIS_ADDRESS"` //host:port, empty disables the tier
+	Password    string        `yaml:"password" env:"REDIS_PASSWORD"`
+	DB          int           `yaml:"db"`
+	Timeout     time.Duration `yaml:"timeout" env-default:"200ms"` //of every command, redirects fall back to the storage after it
+	KeyPrefix   string        `yaml:"key_prefix" env-default:"url-shortener:"`
+	TTL         time.Duration `yaml:"ttl" env-default:"10m"`
+	NegativeTTL time.Duration `yaml:"negative_ttl" env-default:"5s"` //of unknown aliases, 0 disables caching them
 }
 
 func MustLoad() *Config {
@@ -40,4 +131,4 @@ func MustLoad() *Config {
 	}
 
 	return &cfg
-}
\ No newline at end of file
+}
@@ -0,0 +1,105 @@
+package create
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/api/validate"
+	"url-shortener/internal/lib/apikey"
+	my_slog "url-shortener/internal/lib/logger/my_slog"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Request struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=create delete read-stats admin"`
+}
+
+type Response struct {
+	response.Response
+	ID   int64  `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Key is the plaintext api key. It is returned only once and cannot be recovered.
+	Key    string   `json:"key,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+type APIKeySaver interface {
+	SaveAPIKey(ctx context.Context, name string, keyHash string, scopes []string) (int64, error)
+}
+
+func New(log *slog.Logger, keySaver APIKeySaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.apikey.create.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			tracing.TraceID(r.Context()),
+		)
+
+		var req Request
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to decode request body", my_slog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeInvalidRequest, "invalid request body"))
+			return
+		}
+
+		if err := validate.New().Struct(req); err != nil {
+			log.Error("request validation failed", my_slog.Err(err))
+			render.Status(r, http.StatusUnprocessableEntity)
+			render.JSON(w, r, response.ValidationError("validation failed: name and at least one valid scope are required", err))
+			return
+		}
+
+		//the admin name identifies the BasicAuth credential as a link owner
+		if req.Name == auth.AdminName {
+			log.Info("reserved api key name", slog.String("name", req.Name))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, response.Error(response.CodeNameTaken, "api key name is reserved"))
+			return
+		}
+
+		key, err := apikey.Generate()
+		if err != nil {
+			log.Error("failed to generate api key", my_slog.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error(response.CodeInternal, "internal error"))
+			return
+		}
+
+		id, err := keySaver.SaveAPIKey(r.Context(), req.Name, apikey.Hash(key), req.Scopes)
+		if errors.Is(err, storage.ErrAPIKeyExists) {
+			log.Info("api key already exists", slog.String("name", req.Name))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, response.Error(response.CodeNameTaken, "api key with this name already exists"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to save api key", my_slog.Err(err))
+			status, resp := response.StorageError(err, "internal error")
+			render.Status(r, status)
+			render.JSON(w, r, resp)
+			return
+		}
+
+		log.Info("api key created", slog.Int64("id", id), slog.String("name", req.Name))
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			ID:       id,
+			Name:     req.Name,
+			Key:      key,
+			Scopes:   req.Scopes,
+		})
+	}
+}
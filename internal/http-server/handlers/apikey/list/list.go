@@ -0,0 +1,49 @@
+package list
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"url-shortener/internal/lib/api/response"
+	my_slog "url-shortener/internal/lib/logger/my_slog"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	Keys []storage.APIKey `json:"keys"`
+}
+
+type APIKeyLister interface {
+	ListAPIKeys(ctx context.Context) ([]storage.APIKey, error)
+}
+
+func New(log *slog.Logger, keyLister APIKeyLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.apikey.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			tracing.TraceID(r.Context()),
+		)
+
+		keys, err := keyLister.ListAPIKeys(r.Context())
+		if err != nil {
+			log.Error("failed to list api keys", my_slog.Err(err))
+			status, resp := response.StorageError(err, "internal error")
+			render.Status(r, status)
+			render.JSON(w, r, resp)
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			Keys:     keys,
+		})
+	}
+}
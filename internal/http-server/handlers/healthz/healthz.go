@@ -0,0 +1,16 @@
+package healthz
+
+import (
+	"net/http"
+	"url-shortener/internal/lib/api/response"
+
+	"github.com/go-chi/render"
+)
+
+// New reports that the process is alive. It checks no dependencies, so that a
+// database outage does not get every instance restarted.
+func New() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, response.OK())
+	}
+}
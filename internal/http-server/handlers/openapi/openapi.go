@@ -0,0 +1,19 @@
+// Package openapi serves the OpenAPI document of the service.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// Spec is the OpenAPI 3 document describing every route of the router.
+//
+//go:embed openapi.json
+var Spec []byte
+
+func New() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(Spec)
+	}
+}
@@ -0,0 +1,59 @@
+package readyz
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"url-shortener/internal/lib/api/response"
+	my_slog "url-shortener/internal/lib/logger/my_slog"
+	"url-shortener/internal/lib/tracing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type MigrationChecker interface {
+	// Pending returns the number of migrations not applied yet.
+	Pending(ctx context.Context) (int, error)
+}
+
+// New reports whether the instance can serve traffic: the storage is reachable
+// and its schema is up to date.
+func New(log *slog.Logger, pinger Pinger, migrations MigrationChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.readyz.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			tracing.TraceID(r.Context()),
+		)
+
+		if err := pinger.Ping(r.Context()); err != nil {
+			log.Warn("storage is unreachable", my_slog.Err(err))
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, response.Error(response.CodeNotReady, "storage is unreachable"))
+			return
+		}
+
+		pending, err := migrations.Pending(r.Context())
+		if err != nil {
+			log.Warn("failed to check migrations", my_slog.Err(err))
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, response.Error(response.CodeNotReady, "failed to check migrations"))
+			return
+		}
+		if pending > 0 {
+			log.Warn("migrations are pending", slog.Int("count", pending))
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, response.Error(response.CodeNotReady, "migrations are pending"))
+			return
+		}
+
+		render.JSON(w, r, response.OK())
+	}
+}
@@ -1,10 +1,15 @@
 package redirect
 
 import (
+	"context"
 	"errors"
 	"log/slog"
+	"net"
 	"net/http"
+	"time"
 	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/tracing"
 	"url-shortener/internal/storage"
 
 	"github.com/go-chi/chi/v5"
@@ -13,47 +18,80 @@ import (
 )
 
 type URLGetter interface {
-	GetURL(alias string) (string, error)
+	GetURL(ctx context.Context, alias string) (string, error)
 }
 
-func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
+type ClickRecorder interface {
+	Record(click storage.Click)
+}
+
+func New(log *slog.Logger, urlGetter URLGetter, clickRecorder ClickRecorder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "http-server.handlers.redirect.New"
 
 		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
+			tracing.TraceID(r.Context()),
 		)
 
 		alias := chi.URLParam(r, "alias")
 
 		if alias == "" {
 			log.Info("alias is empty")
-			w.WriteHeader(http.StatusBadRequest)
-			render.JSON(w, r, response.Error("invalid request"))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeInvalidRequest, "invalid request"))
 			return
 		}
 
-		resUrl, err := urlGetter.GetURL(alias)
+		resUrl, err := urlGetter.GetURL(r.Context(), alias)
 		if err != nil {
 			if errors.Is(err, storage.ErrUrlNotFound) {
+				metrics.Redirects.WithLabelValues(metrics.RedirectMiss).Inc()
 				log.Info("failed to get URL", slog.String("alias", alias), slog.String("error", err.Error()))
-				w.WriteHeader(http.StatusNotFound)
-				render.JSON(w, r, response.Error("URL not found"))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error(response.CodeNotFound, "URL not found"))
+				return
+			}
+			if errors.Is(err, storage.ErrURLExpired) {
+				metrics.Redirects.WithLabelValues(metrics.RedirectExpired).Inc()
+				log.Info("url expired", slog.String("alias", alias))
+				render.Status(r, http.StatusGone)
+				render.JSON(w, r, response.Error(response.CodeExpired, "URL expired"))
 				return
 			}
+			metrics.Redirects.WithLabelValues(metrics.RedirectError).Inc()
 			log.Info("failed to get URL",
 				slog.String("alias", alias),
 				slog.String("error", err.Error()),
 				slog.String("type", "internal error"),
 			)
-			w.WriteHeader(http.StatusInternalServerError)
-			render.JSON(w, r, response.Error("failed to get URL, internal error"))
+			status, resp := response.StorageError(err, "failed to get URL, internal error")
+			render.Status(r, status)
+			render.JSON(w, r, resp)
 			return
 		}
 
+		metrics.Redirects.WithLabelValues(metrics.RedirectHit).Inc()
 		log.Info("got url", slog.String("url", resUrl))
 
+		clickRecorder.Record(storage.Click{
+			Alias:     alias,
+			ClickedAt: time.Now(),
+			Referrer:  r.Referer(),
+			UserAgent: r.UserAgent(),
+			RequestID: middleware.GetReqID(r.Context()),
+			IP:        clientIP(r),
+		})
+
 		http.Redirect(w, r, resUrl, http.StatusFound)
 	}
 }
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,104 @@
+package delete
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/api/response"
+	my_slog "url-shortener/internal/lib/logger/my_slog"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	// ChunkSize is the number of aliases deleted in one storage transaction.
+	ChunkSize = 500
+	MaxItems  = 10000
+)
+
+type URLDeleter interface {
+	DeleteURLs(ctx context.Context, aliases []string, owner string) ([]error, error)
+}
+
+type Request struct {
+	Aliases []string `json:"aliases"`
+}
+
+// Result is the outcome of the alias at Index of the request.
+type Result struct {
+	Index int `json:"index"`
+	response.Response
+	Alias string `json:"alias"`
+}
+
+type Response struct {
+	response.Response
+	Results []Result `json:"results"`
+}
+
+// New deletes many aliases per request. Like DELETE /url/{alias}, only the
+// creator of a link or an admin may delete it.
+func New(log *slog.Logger, urlDeleter URLDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.url.batch.delete.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			tracing.TraceID(r.Context()),
+		)
+
+		var req Request
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to decode request body", my_slog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeInvalidRequest, "invalid request body"))
+			return
+		}
+		if len(req.Aliases) > MaxItems {
+			log.Info("batch too large", slog.Int("items", len(req.Aliases)))
+			render.Status(r, http.StatusRequestEntityTooLarge)
+			render.JSON(w, r, response.Error(response.CodeTooLarge, fmt.Sprintf("too many items, send at most %d", MaxItems)))
+			return
+		}
+
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		owner := principal.ManagedOwner()
+
+		results := make([]Result, len(req.Aliases))
+		for start := 0; start < len(req.Aliases); start += ChunkSize {
+			aliases := req.Aliases[start:min(start+ChunkSize, len(req.Aliases))]
+
+			errs, err := urlDeleter.DeleteURLs(r.Context(), aliases, owner)
+			if err != nil {
+				log.Error("failed to delete urls", my_slog.Err(err))
+				status, resp := response.StorageError(err, "failed to delete URLs, internal error")
+				render.Status(r, status)
+				render.JSON(w, r, resp)
+				return
+			}
+
+			for j, err := range errs {
+				res := Result{Index: start + j, Alias: aliases[j], Response: response.OK()}
+				switch {
+				case errors.Is(err, storage.ErrUrlNotFound):
+					res.Response = response.Error(response.CodeNotFound, "URL not found")
+				case errors.Is(err, storage.ErrNotOwner):
+					res.Response = response.Error(response.CodeForbidden, "only the owner can delete this URL")
+				case err != nil:
+					res.Response = response.Error(response.CodeInternal, "failed to delete URL, internal error")
+				}
+				results[start+j] = res
+			}
+		}
+
+		log.Info("urls deleted", slog.Int("items", len(req.Aliases)))
+		render.JSON(w, r, Response{Response: response.OK(), Results: results})
+	}
+}
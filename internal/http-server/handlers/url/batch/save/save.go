@@ -0,0 +1,264 @@
+package save
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/api/validate"
+	my_slog "url-shortener/internal/lib/logger/my_slog"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// ChunkSize is the number of urls of an NDJSON stream saved in one storage transaction.
+	ChunkSize = 500
+	// MaxJSONItems limits JSON array requests, which are read fully and saved in
+	// one storage transaction. Larger imports must use NDJSON.
+	MaxJSONItems = 10000
+	// MaxJSONBytes limits the body of JSON array requests before they are decoded.
+	MaxJSONBytes = 16 << 20
+
+	maxAliasAttempts = 10
+
+	contentTypeNDJSON = "application/x-ndjson"
+)
+
+type URLSaver interface {
+	SaveURLs(ctx context.Context, urls []storage.NewURL, encode func(id int64) string) ([]storage.SaveResult, error)
+}
+
+// Result is the outcome of the item at Index of the request.
+type Result struct {
+	Index int `json:"index"`
+	response.Response
+	Alias string `json:"alias,omitempty"`
+}
+
+type Response struct {
+	response.Response
+	Results []Result `json:"results"`
+}
+
+// New saves many urls per request, with the same rules as POST /url for every item.
+// A JSON array is saved in one transaction and answered with all results at once.
+// An NDJSON stream of requests is answered with an NDJSON stream of results, written
+// after every chunk; items of chunks already answered stay saved when the stream
+// turns out to be invalid.
+func New(log *slog.Logger, urlSaver URLSaver, aliasGenerator save.AliasGenerator, idEncoder save.IDEncoder, aliasValidator save.AliasValidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.url.batch.save.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			tracing.TraceID(r.Context()),
+		)
+
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		b := &batch{
+			urlSaver:       urlSaver,
+			aliasGenerator: aliasGenerator,
+			idEncoder:      idEncoder,
+			aliasValidator: aliasValidator,
+			validate:       validate.New(),
+			owner:          principal.Name,
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == contentTypeNDJSON {
+			saveStream(log, w, r, b)
+			return
+		}
+
+		var reqs []storage.Request
+		err := render.DecodeJSON(http.MaxBytesReader(w, r.Body, MaxJSONBytes), &reqs)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			log.Info("batch too large", slog.Int64("limit", maxBytesErr.Limit))
+			render.Status(r, http.StatusRequestEntityTooLarge)
+			render.JSON(w, r, response.Error(response.CodeTooLarge, fmt.Sprintf("body too large, send at most %d bytes or use %s", MaxJSONBytes, contentTypeNDJSON)))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", my_slog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeInvalidRequest, "invalid request body"))
+			return
+		}
+		if len(reqs) > MaxJSONItems {
+			log.Info("batch too large", slog.Int("items", len(reqs)))
+			render.Status(r, http.StatusRequestEntityTooLarge)
+			render.JSON(w, r, response.Error(response.CodeTooLarge, fmt.Sprintf("too many items, send at most %d or use %s", MaxJSONItems, contentTypeNDJSON)))
+			return
+		}
+
+		results, err := b.save(r.Context(), reqs, 0)
+		if err != nil {
+			log.Error("failed to save urls", my_slog.Err(err))
+			status, resp := response.StorageError(err, "internal server error, failed to add urls")
+			render.Status(r, status)
+			render.JSON(w, r, Response{Response: resp, Results: results})
+			return
+		}
+
+		log.Info("urls saved", slog.Int("items", len(reqs)))
+		render.JSON(w, r, Response{Response: response.OK(), Results: results})
+	}
+}
+
+// saveStream answers an NDJSON stream of requests with an NDJSON stream of results.
+func saveStream(log *slog.Logger, w http.ResponseWriter, r *http.Request, b *batch) {
+	//imports may take far longer than the server timeouts meant for single requests
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", contentTypeNDJSON)
+	enc := json.NewEncoder(w)
+	dec := json.NewDecoder(r.Body)
+
+	total := 0
+	for {
+		var reqs []storage.Request
+		var decodeErr error
+		for len(reqs) < ChunkSize {
+			var req storage.Request
+			if decodeErr = dec.Decode(&req); decodeErr != nil {
+				break
+			}
+			reqs = append(reqs, req)
+		}
+
+		results, err := b.save(r.Context(), reqs, total)
+		if err != nil {
+			log.Error("failed to save urls", my_slog.Err(err), slog.Int("saved", total))
+			for _, res := range results {
+				_ = enc.Encode(res)
+			}
+			_, resp := response.StorageError(err, "internal server error, failed to add urls")
+			_ = enc.Encode(resp)
+			return
+		}
+		for _, res := range results {
+			_ = enc.Encode(res)
+		}
+		_ = rc.Flush()
+		total += len(reqs)
+
+		if errors.Is(decodeErr, io.EOF) {
+			log.Info("urls saved", slog.Int("items", total))
+			return
+		}
+		if decodeErr != nil {
+			log.Error("failed to decode request body", my_slog.Err(decodeErr), slog.Int("saved", total))
+			_ = enc.Encode(response.Error(response.CodeInvalidRequest, fmt.Sprintf("invalid request body after item %d", total-1)))
+			return
+		}
+	}
+}
+
+type batch struct {
+	urlSaver       URLSaver
+	aliasGenerator save.AliasGenerator
+	idEncoder      save.IDEncoder
+	aliasValidator save.AliasValidator
+	validate       *validator.Validate
+	owner          string
+}
+
+// save saves reqs in one transaction, retrying generated aliases that are taken,
+// and returns their results numbered from offset. When the storage fails, the
+// results tell the items saved by earlier transactions from those that failed.
+func (b *batch) save(ctx context.Context, reqs []storage.Request, offset int) ([]Result, error) {
+	results := make([]Result, len(reqs))
+
+	var (
+		urls    []storage.NewURL
+		indexes []int //of urls in reqs
+	)
+	now := time.Now()
+	for i, req := range reqs {
+		results[i].Index = offset + i
+
+		if err := b.validate.Struct(req); err != nil {
+			results[i].Response = response.ValidationError("validation failed: invalid url format or missing required fields", err)
+			continue
+		}
+		if req.Alias != "" {
+			if err := b.aliasValidator.Validate(req.Alias); err != nil {
+				results[i].Response = response.Error(response.CodeInvalidAlias, err.Error())
+				continue
+			}
+		}
+		expiresAt, err := req.Expiration(now)
+		if err != nil {
+			results[i].Response = response.Error(response.CodeInvalidExpiration, err.Error())
+			continue
+		}
+
+		u := storage.NewURL{URL: req.URL, Alias: req.Alias, Owner: b.owner, ExpiresAt: expiresAt}
+		if u.Alias == "" && b.idEncoder == nil {
+			u.Alias = b.aliasGenerator.Generate()
+		}
+		urls = append(urls, u)
+		indexes = append(indexes, i)
+	}
+
+	var encode func(id int64) string
+	if b.idEncoder != nil {
+		encode = b.idEncoder.Encode
+	}
+
+	for attempt := 1; len(urls) > 0; attempt++ {
+		saved, err := b.urlSaver.SaveURLs(ctx, urls, encode)
+		if err != nil {
+			_, resp := response.StorageError(err, "internal server error, failed to add url")
+			for _, i := range indexes {
+				results[i].Response = resp
+			}
+			return results, err
+		}
+
+		var retryURLs []storage.NewURL
+		var retryIndexes []int
+		for j, res := range saved {
+			i := indexes[j]
+			switch {
+			case res.Err == nil:
+				results[i].Response = response.OK()
+				results[i].Alias = res.Alias
+			//like POST /url, a taken custom alias fails its item, a generated one is replaced
+			case errors.Is(res.Err, storage.ErrURLExists) && reqs[i].Alias != "":
+				results[i].Response = response.Error(response.CodeAliasTaken, "alias already taken")
+			case errors.Is(res.Err, storage.ErrURLExists) && attempt < maxAliasAttempts:
+				metrics.AliasRetries.Inc()
+				u := urls[j]
+				u.Alias = b.aliasGenerator.Generate()
+				retryURLs = append(retryURLs, u)
+				retryIndexes = append(retryIndexes, i)
+			case errors.Is(res.Err, storage.ErrURLExists):
+				results[i].Response = response.Error(response.CodeAliasTaken, "failed to generate unique alias")
+			default:
+				results[i].Response = response.Error(response.CodeInternal, "internal server error, failed to add url")
+			}
+		}
+		urls, indexes = retryURLs, retryIndexes
+	}
+
+	return results, nil
+}
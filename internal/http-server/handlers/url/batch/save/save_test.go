@@ -0,0 +1,183 @@
+package save_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"url-shortener/internal/http-server/handlers/url/batch/save"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/aliaspolicy"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockURLSaver struct {
+	mock.Mock
+}
+
+func (m *MockURLSaver) SaveURLs(_ context.Context, urls []storage.NewURL, encode func(id int64) string) ([]storage.SaveResult, error) {
+	args := m.Called(urls)
+	return args.Get(0).([]storage.SaveResult), args.Error(1)
+}
+
+type stubGenerator struct {
+	aliases []string
+}
+
+func (g *stubGenerator) Generate() string {
+	alias := g.aliases[0]
+	g.aliases = g.aliases[1:]
+	return alias
+}
+
+func newHandler(t *testing.T, m *MockURLSaver, generated ...string) http.HandlerFunc {
+	policy, err := aliaspolicy.New(aliaspolicy.Options{MinLength: 3, MaxLength: 64, Reserved: aliaspolicy.DefaultReserved})
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	return save.New(logger, m, &stubGenerator{aliases: generated}, nil, policy)
+}
+
+func serve(handler http.HandlerFunc, contentType string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/url/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Name: "alice", Scopes: []string{auth.ScopeCreate}}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestBatchSave_JSON(t *testing.T) {
+	m := new(MockURLSaver)
+	m.On("SaveURLs", []storage.NewURL{
+		{URL: "https://a.com", Alias: "alias_a", Owner: "alice"},
+		{URL: "https://c.com", Alias: "gen1", Owner: "alice"},
+		{URL: "https://e.com", Alias: "taken", Owner: "alice"},
+	}).Return([]storage.SaveResult{
+		{Saved: storage.Saved{ID: 1, Alias: "alias_a", Created: true}},
+		{Err: storage.ErrURLExists},
+		{Err: storage.ErrURLExists},
+	}, nil).Once()
+	//the taken generated alias is retried alone, the taken custom one fails
+	m.On("SaveURLs", []storage.NewURL{
+		{URL: "https://c.com", Alias: "gen2", Owner: "alice"},
+	}).Return([]storage.SaveResult{
+		{Saved: storage.Saved{ID: 2, Alias: "gen2", Created: true}},
+	}, nil).Once()
+
+	rr := serve(newHandler(t, m, "gen1", "gen2"), "application/json", `[
+		{"url": "https://a.com", "alias": "alias_a"},
+		{"url": "not a url"},
+		{"url": "https://c.com"},
+		{"url": "https://d.com", "alias": "admin"},
+		{"url": "https://e.com", "alias": "taken"}
+	]`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp save.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 5)
+
+	assert.Equal(t, "alias_a", resp.Results[0].Alias)
+	assert.Contains(t, resp.Results[1].Error, "validation failed")
+	assert.Equal(t, "gen2", resp.Results[2].Alias)
+	assert.Equal(t, `invalid alias: "admin" is reserved`, resp.Results[3].Error)
+	assert.Equal(t, "alias_taken", resp.Results[4].Code)
+	for i, res := range resp.Results {
+		assert.Equal(t, i, res.Index)
+	}
+
+	m.AssertExpectations(t)
+}
+
+func TestBatchSave_NDJSON(t *testing.T) {
+	var body strings.Builder
+	var want []storage.NewURL
+	var saved []storage.SaveResult
+	for i := 0; i < save.ChunkSize+1; i++ {
+		body.WriteString(`{"url": "https://example.com", "alias": "alias` + strings.Repeat("x", i%3) + `"}` + "\n")
+		alias := "alias" + strings.Repeat("x", i%3)
+		want = append(want, storage.NewURL{URL: "https://example.com", Alias: alias, Owner: "alice"})
+		saved = append(saved, storage.SaveResult{Saved: storage.Saved{Alias: alias}})
+	}
+	body.WriteString(`{"url": `)
+
+	m := new(MockURLSaver)
+	m.On("SaveURLs", want[:save.ChunkSize]).Return(saved[:save.ChunkSize], nil).Once()
+	m.On("SaveURLs", want[save.ChunkSize:]).Return(saved[save.ChunkSize:], nil).Once()
+
+	rr := serve(newHandler(t, m), "application/x-ndjson", body.String())
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+
+	var lines []string
+	sc := bufio.NewScanner(rr.Body)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	require.Len(t, lines, save.ChunkSize+2)
+
+	var last save.Result
+	require.NoError(t, json.Unmarshal([]byte(lines[save.ChunkSize]), &last))
+	assert.Equal(t, save.ChunkSize, last.Index)
+	assert.Equal(t, "OK", last.Status)
+	assert.Contains(t, lines[save.ChunkSize+1], "invalid request body after item 500")
+
+	m.AssertExpectations(t)
+}
+
+func TestBatchSave_TooLarge(t *testing.T) {
+	body := "[" + strings.Repeat(`{"url": "https://example.com"},`, save.MaxJSONItems) + `{"url": "https://example.com"}]`
+
+	rr := serve(newHandler(t, new(MockURLSaver)), "application/json", body)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestBatchSave_TooManyBytes(t *testing.T) {
+	body := `[{"url": "https://example.com/` + strings.Repeat("a", save.MaxJSONBytes) + `"}]`
+
+	rr := serve(newHandler(t, new(MockURLSaver)), "application/json", body)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"code":"too_large"`)
+}
+
+func TestBatchSave_StorageError(t *testing.T) {
+	m := new(MockURLSaver)
+	m.On("SaveURLs", []storage.NewURL{
+		{URL: "https://a.com", Alias: "gen1", Owner: "alice"},
+		{URL: "https://b.com", Alias: "alias_b", Owner: "alice"},
+	}).Return([]storage.SaveResult{
+		{Err: storage.ErrURLExists},
+		{Saved: storage.Saved{ID: 1, Alias: "alias_b", Created: true}},
+	}, nil).Once()
+	m.On("SaveURLs", []storage.NewURL{
+		{URL: "https://a.com", Alias: "gen2", Owner: "alice"},
+	}).Return([]storage.SaveResult(nil), errors.New("disk I/O error")).Once()
+
+	rr := serve(newHandler(t, m, "gen1", "gen2"), "application/json", `[
+		{"url": "https://a.com"},
+		{"url": "https://b.com", "alias": "alias_b"}
+	]`)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	//the client learns which items the first transaction saved
+	var resp save.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, "internal_error", resp.Code)
+	require.Len(t, resp.Results, 2)
+	assert.Equal(t, "internal_error", resp.Results[0].Code)
+	assert.Equal(t, "OK", resp.Results[1].Status)
+	assert.Equal(t, "alias_b", resp.Results[1].Alias)
+
+	m.AssertExpectations(t)
+}
@@ -1,42 +1,58 @@
 package delete
 
 import (
+	"context"
 	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"log/slog"
 	"net/http"
+	"url-shortener/internal/http-server/middleware/auth"
 	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/tracing"
 	"url-shortener/internal/storage"
 )
 
 type UrlDeleter interface {
-	DeleteURL(alias string) error
+	DeleteURL(ctx context.Context, alias string, owner string) error
 }
 
 func New(log *slog.Logger, urlDeleter UrlDeleter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "http-server.handlers.url.delete.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
+			tracing.TraceID(r.Context()),
 		)
 
 		alias := chi.URLParam(r, "alias")
 
 		if alias == "" {
 			log.Info("alias is empty")
-			render.JSON(w, r, response.Error("invalid request"))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeInvalidRequest, "invalid request"))
 			return
 		}
 
-		err := urlDeleter.DeleteURL(alias)
+		//only the creator of a link or an admin may delete it
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		owner := principal.ManagedOwner()
+
+		err := urlDeleter.DeleteURL(r.Context(), alias, owner)
 		if err != nil {
 			if errors.Is(err, storage.ErrUrlNotFound) {
 				log.Info("failed to get URL", slog.String("alias", alias), slog.String("error", err.Error()))
-				render.JSON(w, r, response.Error("URL not found"))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error(response.CodeNotFound, "URL not found"))
+				return
+			}
+			if errors.Is(err, storage.ErrNotOwner) {
+				log.Info("not an owner of url", slog.String("alias", alias), slog.String("principal", principal.Name))
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, response.Error(response.CodeForbidden, "only the owner can delete this URL"))
 				return
 			}
 			log.Info("failed to get URL",
@@ -44,7 +60,9 @@ func New(log *slog.Logger, urlDeleter UrlDeleter) http.HandlerFunc {
 				slog.String("error", err.Error()),
 				slog.String("type", "internal error"),
 			)
-			render.JSON(w, r, response.Error("failed to get URL, internal error"))
+			status, resp := response.StorageError(err, "failed to get URL, internal error")
+			render.Status(r, status)
+			render.JSON(w, r, resp)
 			return
 		}
 		log.Info("deleted url", slog.String("alias", alias))
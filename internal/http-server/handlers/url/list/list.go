@@ -0,0 +1,155 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/api/response"
+	my_slog "url-shortener/internal/lib/logger/my_slog"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	// ownerMe in the owner query parameter stands for the caller.
+	ownerMe = "me"
+
+	defaultLimit = 50
+	maxLimit     = 1000
+)
+
+type Response struct {
+	response.Response
+	URLs       []storage.URL `json:"urls"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type URLLister interface {
+	ListURLs(ctx context.Context, filter storage.ListFilter) ([]storage.URL, string, error)
+}
+
+// New lists saved urls page by page. Query parameters:
+//
+//	owner          "me", or an owner name (admins only)
+//	q              substring of the url
+//	domain         host of the url, subdomains included
+//	alias_prefix   prefix of the alias
+//	created_from   RFC 3339, inclusive
+//	created_to     RFC 3339, exclusive
+//	sort           created_at, -created_at (default), alias or -alias
+//	limit          page size, 1..1000, default 50
+//	cursor         next_cursor of the previous page
+//
+// Non-admin callers only ever see their own links.
+func New(log *slog.Logger, urlLister URLLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.url.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			tracing.TraceID(r.Context()),
+		)
+
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		isAdmin := principal.HasScope(auth.ScopeAdmin)
+
+		query := r.URL.Query()
+
+		owner := query.Get("owner")
+		switch {
+		case owner == ownerMe:
+			owner = principal.Name
+		case owner == "" && !isAdmin:
+			owner = principal.Name
+		case owner != principal.Name && !isAdmin:
+			log.Info("listing urls of another owner is forbidden", slog.String("owner", owner))
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, response.Error(response.CodeForbidden, "only admins can list urls of other owners"))
+			return
+		}
+
+		filter, err := parseFilter(query)
+		if err != nil {
+			log.Info("invalid list query", my_slog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeInvalidRequest, err.Error()))
+			return
+		}
+		filter.Owner = owner
+
+		urls, next, err := urlLister.ListURLs(r.Context(), filter)
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			log.Info("invalid cursor", my_slog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeInvalidRequest, "invalid cursor"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to list urls", my_slog.Err(err))
+			status, resp := response.StorageError(err, "internal error")
+			render.Status(r, status)
+			render.JSON(w, r, resp)
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response:   response.OK(),
+			URLs:       urls,
+			NextCursor: next,
+		})
+	}
+}
+
+func parseFilter(query url.Values) (storage.ListFilter, error) {
+	filter := storage.ListFilter{
+		URLContains: query.Get("q"),
+		Domain:      query.Get("domain"),
+		AliasPrefix: query.Get("alias_prefix"),
+		Cursor:      query.Get("cursor"),
+		Limit:       defaultLimit,
+		Sort:        storage.SortCreatedAt,
+		Desc:        true,
+	}
+
+	for name, dst := range map[string]*time.Time{
+		"created_from": &filter.CreatedFrom,
+		"created_to":   &filter.CreatedTo,
+	} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return storage.ListFilter{}, fmt.Errorf("invalid %s: expected RFC 3339 time", name)
+			}
+			*dst = t
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return storage.ListFilter{}, fmt.Errorf("invalid limit: expected 1..%d", maxLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if v := query.Get("sort"); v != "" {
+		filter.Desc = strings.HasPrefix(v, "-")
+		filter.Sort = strings.TrimPrefix(v, "-")
+		if filter.Sort != storage.SortCreatedAt && filter.Sort != storage.SortAlias {
+			return storage.ListFilter{}, errors.New("invalid sort: expected created_at or alias, optionally prefixed with -")
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,120 @@
+package list_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/list"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockURLLister struct {
+	mock.Mock
+}
+
+func (m *MockURLLister) ListURLs(_ context.Context, filter storage.ListFilter) ([]storage.URL, string, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]storage.URL), args.String(1), args.Error(2)
+}
+
+func TestListHandler(t *testing.T) {
+	alice := auth.Principal{Name: "alice", Scopes: []string{auth.ScopeCreate}}
+	admin := auth.Principal{Name: auth.AdminName, Scopes: []string{auth.ScopeAdmin}}
+
+	cases := []struct {
+		name       string
+		query      string
+		principal  auth.Principal
+		wantFilter *storage.ListFilter
+		wantStatus int
+	}{
+		{
+			name:      "Defaults To Own Links",
+			query:     "",
+			principal: alice,
+			wantFilter: &storage.ListFilter{
+				Owner: "alice", Sort: storage.SortCreatedAt, Desc: true, Limit: 50,
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:      "Admin Lists Everything",
+			query:     "",
+			principal: admin,
+			wantFilter: &storage.ListFilter{
+				Sort: storage.SortCreatedAt, Desc: true, Limit: 50,
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:      "All Filters",
+			query:     "owner=me&q=docs&domain=example.com&alias_prefix=ab&created_from=2025-01-01T00:00:00Z&created_to=2025-02-01T00:00:00Z&sort=alias&limit=10&cursor=abc",
+			principal: alice,
+			wantFilter: &storage.ListFilter{
+				Owner:       "alice",
+				URLContains: "docs",
+				Domain:      "example.com",
+				AliasPrefix: "ab",
+				CreatedFrom: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+				CreatedTo:   time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+				Sort:        storage.SortAlias,
+				Limit:       10,
+				Cursor:      "abc",
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Other Owner Forbidden",
+			query:      "owner=bob",
+			principal:  alice,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Invalid Limit",
+			query:      "limit=0",
+			principal:  alice,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid Sort",
+			query:      "sort=url",
+			principal:  alice,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid Date",
+			query:      "created_from=yesterday",
+			principal:  alice,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			lister := new(MockURLLister)
+			if tc.wantFilter != nil {
+				lister.On("ListURLs", *tc.wantFilter).Return([]storage.URL{}, "", nil)
+			}
+
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			handler := list.New(logger, lister)
+
+			req := httptest.NewRequest(http.MethodGet, "/url?"+tc.query, nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), tc.principal))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code)
+			lister.AssertExpectations(t)
+		})
+	}
+}
@@ -1,36 +1,52 @@
 package save
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
+	"time"
+	"url-shortener/internal/http-server/middleware/auth"
 	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/api/validate"
 	my_slog "url-shortener/internal/lib/logger/my_slog"
-	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/tracing"
 	"url-shortener/internal/storage"
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
-	"github.com/go-playground/validator/v10"
-)
-
-const (
-	aliasLength = 8
 )
 
 type UrlSaver interface {
-	SaveURL(urlToSave string, alias string) (int64, error)
-	GetURL(alias string) (string, error)
-	GetAliasByURL(url string) (string, error)
+	SaveURLIfAbsent(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (storage.Saved, error)
+	SaveURLWithIDAlias(ctx context.Context, urlToSave string, owner string, expiresAt time.Time, encode func(id int64) string) (storage.Saved, error)
+}
+
+type AliasGenerator interface {
+	Generate() string
+}
+
+// AliasValidator rejects custom aliases that break the policy, with a message for the client.
+type AliasValidator interface {
+	Validate(alias string) error
+}
+
+// IDEncoder derives aliases from row ids, so generated aliases need no uniqueness checks.
+type IDEncoder interface {
+	Encode(id int64) string
 }
 
-func New(log *slog.Logger, urlSaver UrlSaver) http.HandlerFunc {
+// New saves urls. Aliases missing from the request come from idEncoder when it is
+// not nil (the sequence alias strategy), and from aliasGenerator otherwise.
+func New(log *slog.Logger, urlSaver UrlSaver, aliasGenerator AliasGenerator, idEncoder IDEncoder, aliasValidator AliasValidator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "http-server.handlers.url.save.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
+			tracing.TraceID(r.Context()),
 		)
 		var req storage.Request
 
@@ -38,70 +54,103 @@ func New(log *slog.Logger, urlSaver UrlSaver) http.HandlerFunc {
 
 		if err != nil {
 			log.Error("failed to decode request body", my_slog.Err(err))
-			render.JSON(w, r, response.Error("invalid request body"))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeInvalidRequest, "invalid request body"))
 			return
 		}
 
 		log.Info("request body decoded", slog.Any("request", req))
 
-		if err := validator.New().Struct(req); err != nil {
+		if err := validate.New().Struct(req); err != nil {
 			log.Error("request validation failed", my_slog.Err(err))
 
-			render.JSON(w, r, response.Error("validation failed: invalid url format or missing required fields"))
+			render.Status(r, http.StatusUnprocessableEntity)
+			render.JSON(w, r, response.ValidationError("validation failed: invalid url format or missing required fields", err))
 
 			return
 		}
-		//check for this url existing
-		if existingAlias, err := urlSaver.GetAliasByURL(req.URL); err == nil {
-			//exists
-			storage.ResponseOK(w, r, existingAlias)
-			return
-		} else if !errors.Is(err, storage.ErrUrlNotFound) {
-			log.Error("failed to check existing url", my_slog.Err(err))
-			render.JSON(w, r, response.Error("internal error"))
-			return
-		}
 
-		alias := req.Alias
-		if alias == "" {
-			alias = random.GenerateRandomString(aliasLength)
-		}
+		if req.Alias != "" {
+			if err := aliasValidator.Validate(req.Alias); err != nil {
+				log.Info("alias rejected", slog.String("alias", req.Alias), my_slog.Err(err))
 
-		for {
-			_, err = urlSaver.GetURL(alias)
-			if err == nil {
-				// alias exists
-				alias = random.GenerateRandomString(aliasLength)
-				continue
-			}
+				render.Status(r, http.StatusUnprocessableEntity)
+				render.JSON(w, r, response.Error(response.CodeInvalidAlias, err.Error()))
 
-			if errors.Is(err, storage.ErrUrlNotFound) {
-				// alias unique
-				break
+				return
 			}
+		}
+
+		expiresAt, err := req.Expiration(time.Now())
+		if err != nil {
+			log.Error("invalid expiration", my_slog.Err(err))
+
+			render.Status(r, http.StatusUnprocessableEntity)
+			render.JSON(w, r, response.Error(response.CodeInvalidExpiration, err.Error()))
 
-			log.Error("failed to check existing alias", my_slog.Err(err))
-			render.JSON(w, r, response.Error("internal error"))
 			return
 		}
 
-		id, err := urlSaver.SaveURL(req.URL, alias)
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		owner := principal.Name
+
+		var saved storage.Saved
+		switch {
+		case req.Alias != "":
+			saved, err = urlSaver.SaveURLIfAbsent(r.Context(), req.URL, req.Alias, owner, expiresAt)
+		case idEncoder != nil:
+			saved, err = urlSaver.SaveURLWithIDAlias(r.Context(), req.URL, owner, expiresAt, idEncoder.Encode)
+		default:
+			saved, err = saveWithGeneratedAlias(r.Context(), urlSaver, aliasGenerator, req.URL, owner, expiresAt)
+		}
+		if errors.Is(err, storage.ErrURLExists) && req.Alias != "" {
+			log.Info("alias already taken", slog.String("alias", req.Alias))
+
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, response.Error(response.CodeAliasTaken, "alias already taken"))
+
+			return
+		}
 		if errors.Is(err, storage.ErrURLExists) {
-			log.Warn("url already exists", slog.String("url", req.URL))
+			log.Error("no free alias left", my_slog.Err(err))
 
-			render.JSON(w, r, response.Error("url with this alias already exists"))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, response.Error(response.CodeAliasTaken, "failed to generate unique alias"))
 
 			return
 		}
 		if err != nil {
 			log.Error("failed to add url", my_slog.Err(err))
 
-			render.JSON(w, r, response.Error("internal server error, failed to add url"))
+			status, resp := response.StorageError(err, "internal server error, failed to add url")
+			render.Status(r, status)
+			render.JSON(w, r, resp)
 
 			return
 		}
 
-		log.Info("url added", slog.Int64("id", id))
-		storage.ResponseOK(w, r, alias)
+		if saved.Created {
+			log.Info("url added", slog.Int64("id", saved.ID))
+		} else {
+			log.Info("url already exists", slog.Int64("id", saved.ID))
+		}
+		storage.ResponseOK(w, r, saved.Alias)
+	}
+}
+
+// maxAliasAttempts bounds the retries on generated aliases that turn out to be taken.
+const maxAliasAttempts = 10
+
+// saveWithGeneratedAlias saves the url under a generated alias, generating another
+// one while they are taken. The check and the insert are a single storage
+// operation, so concurrent saves cannot take the same alias.
+func saveWithGeneratedAlias(ctx context.Context, urlSaver UrlSaver, aliasGenerator AliasGenerator, url string, owner string, expiresAt time.Time) (storage.Saved, error) {
+	for attempt := 1; ; attempt++ {
+		saved, err := urlSaver.SaveURLIfAbsent(ctx, url, aliasGenerator.Generate(), owner, expiresAt)
+		if !errors.Is(err, storage.ErrURLExists) || attempt == maxAliasAttempts {
+			return saved, err
+		}
+
+		metrics.AliasRetries.Inc()
 	}
 }
@@ -2,13 +2,18 @@ package save_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 	save "url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/aliaspolicy"
 	"url-shortener/internal/storage"
 
 	"github.com/stretchr/testify/mock"
@@ -19,19 +24,32 @@ type MockURLSaver struct {
 	mock.Mock
 }
 
-func (m *MockURLSaver) SaveURL(urlToSave string, alias string) (int64, error) {
-	args := m.Called(urlToSave, alias)
-	return args.Get(0).(int64), args.Error(1)
+func (m *MockURLSaver) SaveURLIfAbsent(_ context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (storage.Saved, error) {
+	args := m.Called(urlToSave, alias, owner, expiresAt)
+	return args.Get(0).(storage.Saved), args.Error(1)
 }
 
-func (m *MockURLSaver) GetURL(alias string) (string, error) {
-	args := m.Called(alias)
-	return args.String(0), args.Error(1)
+func (m *MockURLSaver) SaveURLWithIDAlias(_ context.Context, urlToSave string, owner string, expiresAt time.Time, encode func(id int64) string) (storage.Saved, error) {
+	args := m.Called(urlToSave, owner, expiresAt)
+	id := args.Get(0).(int64)
+	if err := args.Error(1); err != nil {
+		return storage.Saved{}, err
+	}
+	return storage.Saved{ID: id, Alias: encode(id), Created: true}, nil
+}
+
+type stubEncoder struct{}
+
+func (stubEncoder) Encode(id int64) string {
+	return fmt.Sprintf("id%d", id)
 }
 
-func (m *MockURLSaver) GetAliasByURL(url string) (string, error) {
-	args := m.Called(url)
-	return args.String(0), args.Error(1)
+type stubGenerator struct {
+	alias string
+}
+
+func (g stubGenerator) Generate() string {
+	return g.alias
 }
 
 func TestSaveHandler(t *testing.T) {
@@ -39,38 +57,136 @@ func TestSaveHandler(t *testing.T) {
 		name      string
 		alias     string
 		url       string
+		ttl       string
+		sequence  bool
+		respAlias string
 		respError string
+		respCode  string
+		status    int
 		mockSetup func(m *MockURLSaver)
 	}{
 		{
-			name:  "Success",
-			alias: "test_alias",
-			url:   "https://google.com",
+			name:      "Success",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			respAlias: "test_alias",
 			mockSetup: func(m *MockURLSaver) {
-				m.On("GetAliasByURL", "https://google.com").Return("", storage.ErrUrlNotFound)
-				m.On("GetURL", "test_alias").Return("", storage.ErrUrlNotFound)
-				m.On("SaveURL", "https://google.com", "test_alias").Return(int64(1), nil)
+				m.On("SaveURLIfAbsent", "https://google.com", "test_alias", "alice", time.Time{}).
+					Return(storage.Saved{ID: 1, Alias: "test_alias", Created: true}, nil)
 			},
 		},
 		{
-			name:  "URL Already Exists",
-			alias: "new_alias",
-			url:   "https://google.com",
+			name:      "Generated Alias",
+			alias:     "",
+			url:       "https://google.com",
+			respAlias: "generated",
 			mockSetup: func(m *MockURLSaver) {
-				m.On("GetAliasByURL", "https://google.com").Return("existing_alias", nil)
+				m.On("SaveURLIfAbsent", "https://google.com", "generated", "alice", time.Time{}).
+					Return(storage.Saved{ID: 1, Alias: "generated", Created: true}, nil)
 			},
 		},
 		{
-			name:      "Alias Conflict (Save Error)",
+			name:      "Sequence Alias",
+			url:       "https://google.com",
+			sequence:  true,
+			respAlias: "id42",
+			mockSetup: func(m *MockURLSaver) {
+				m.On("SaveURLWithIDAlias", "https://google.com", "alice", time.Time{}).Return(int64(42), nil)
+			},
+		},
+		{
+			name:      "Sequence Keeps Custom Alias",
+			alias:     "custom",
+			url:       "https://google.com",
+			sequence:  true,
+			respAlias: "custom",
+			mockSetup: func(m *MockURLSaver) {
+				m.On("SaveURLIfAbsent", "https://google.com", "custom", "alice", time.Time{}).
+					Return(storage.Saved{ID: 1, Alias: "custom", Created: true}, nil)
+			},
+		},
+		{
+			name:      "URL Already Exists",
+			alias:     "new_alias",
+			url:       "https://google.com",
+			respAlias: "existing_alias",
+			mockSetup: func(m *MockURLSaver) {
+				m.On("SaveURLIfAbsent", "https://google.com", "new_alias", "alice", time.Time{}).
+					Return(storage.Saved{ID: 1, Alias: "existing_alias"}, nil)
+			},
+		},
+		{
+			name:      "Alias Taken",
 			alias:     "test_alias",
 			url:       "https://google.com",
-			respError: "url with this alias already exists",
+			respError: "alias already taken",
+			respCode:  "alias_taken",
+			status:    http.StatusConflict,
 			mockSetup: func(m *MockURLSaver) {
-				m.On("GetAliasByURL", "https://google.com").Return("", storage.ErrUrlNotFound)
-				m.On("GetURL", "test_alias").Return("", storage.ErrUrlNotFound)
-				m.On("SaveURL", "https://google.com", "test_alias").Return(int64(0), storage.ErrURLExists)
+				m.On("SaveURLIfAbsent", "https://google.com", "test_alias", "alice", time.Time{}).
+					Return(storage.Saved{}, storage.ErrURLExists).Once()
 			},
 		},
+		{
+			name:      "Reserved Alias",
+			alias:     "Admin",
+			url:       "https://google.com",
+			respError: `invalid alias: \"Admin\" is reserved`,
+			respCode:  "invalid_alias",
+			status:    http.StatusUnprocessableEntity,
+		},
+		{
+			name:      "Alias With Slash",
+			alias:     "a/b",
+			url:       "https://google.com",
+			respError: `invalid alias: character '/' is not allowed`,
+			respCode:  "invalid_alias",
+			status:    http.StatusUnprocessableEntity,
+		},
+		{
+			name:      "No Free Alias",
+			alias:     "",
+			url:       "https://google.com",
+			respError: "failed to generate unique alias",
+			respCode:  "alias_taken",
+			status:    http.StatusConflict,
+			mockSetup: func(m *MockURLSaver) {
+				m.On("SaveURLIfAbsent", "https://google.com", "generated", "alice", time.Time{}).
+					Return(storage.Saved{}, storage.ErrURLExists)
+			},
+		},
+		{
+			name:      "Storage Timeout",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			respError: "storage timeout",
+			respCode:  "timeout",
+			status:    http.StatusServiceUnavailable,
+			mockSetup: func(m *MockURLSaver) {
+				m.On("SaveURLIfAbsent", "https://google.com", "test_alias", "alice", time.Time{}).
+					Return(storage.Saved{}, fmt.Errorf("storage.sqlite.SaveURLIfAbsent: %w", context.DeadlineExceeded))
+			},
+		},
+		{
+			name:  "With TTL",
+			alias: "ttl_alias",
+			url:   "https://google.com",
+			ttl:   "1h",
+			mockSetup: func(m *MockURLSaver) {
+				m.On("SaveURLIfAbsent", "https://google.com", "ttl_alias", "alice", mock.MatchedBy(func(t time.Time) bool {
+					return t.After(time.Now().Add(59*time.Minute)) && t.Before(time.Now().Add(time.Hour))
+				})).Return(storage.Saved{ID: 1, Alias: "ttl_alias", Created: true}, nil)
+			},
+		},
+		{
+			name:      "Invalid TTL",
+			alias:     "ttl_alias",
+			url:       "https://google.com",
+			ttl:       "-1h",
+			respError: "invalid ttl",
+			respCode:  "invalid_expiration",
+			status:    http.StatusUnprocessableEntity,
+		},
 	}
 
 	for _, tc := range cases {
@@ -81,24 +197,39 @@ func TestSaveHandler(t *testing.T) {
 			}
 
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-			handler := save.New(logger, urlSaverMock)
+			var idEncoder save.IDEncoder
+			if tc.sequence {
+				idEncoder = stubEncoder{}
+			}
+			policy, err := aliaspolicy.New(aliaspolicy.Options{MinLength: 3, MaxLength: 64, Reserved: aliaspolicy.DefaultReserved})
+			require.NoError(t, err)
+			handler := save.New(logger, urlSaverMock, stubGenerator{"generated"}, idEncoder, policy)
 
 			input := storage.Request{
 				URL:   tc.url,
 				Alias: tc.alias,
+				TTL:   tc.ttl,
 			}
 
 			body, _ := json.Marshal(input)
 			req, _ := http.NewRequest(http.MethodPost, "/save", bytes.NewReader(body))
+			req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Name: "alice", Scopes: []string{auth.ScopeCreate}}))
 			rr := httptest.NewRecorder()
 
 			handler.ServeHTTP(rr, req)
 
 			if tc.respError == "" {
 				require.Equal(t, http.StatusOK, rr.Code)
+				if tc.respAlias != "" {
+					require.Contains(t, rr.Body.String(), `"alias":"`+tc.respAlias+`"`)
+				}
 			} else {
+				require.Equal(t, tc.status, rr.Code)
 				require.Contains(t, rr.Body.String(), tc.respError)
+				require.Contains(t, rr.Body.String(), `"code":"`+tc.respCode+`"`)
 			}
+
+			urlSaverMock.AssertExpectations(t)
 		})
 	}
 }
@@ -0,0 +1,95 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/api/response"
+	my_slog "url-shortener/internal/lib/logger/my_slog"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type StatsGetter interface {
+	GetURLOwner(ctx context.Context, alias string) (string, error)
+	GetStats(ctx context.Context, alias string) (storage.Stats, error)
+}
+
+type Response struct {
+	response.Response
+	Alias string `json:"alias"`
+	storage.Stats
+}
+
+func New(log *slog.Logger, statsGetter StatsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.url.stats.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			tracing.TraceID(r.Context()),
+		)
+
+		alias := chi.URLParam(r, "alias")
+
+		if alias == "" {
+			log.Info("alias is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeInvalidRequest, "invalid request"))
+			return
+		}
+
+		//only the creator of a link or an admin may read its stats
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		if managed := principal.ManagedOwner(); managed != "" {
+			owner, err := statsGetter.GetURLOwner(r.Context(), alias)
+			if errors.Is(err, storage.ErrUrlNotFound) {
+				log.Info("url not found", slog.String("alias", alias))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error(response.CodeNotFound, "URL not found"))
+				return
+			}
+			if err != nil {
+				log.Error("failed to get url owner", slog.String("alias", alias), my_slog.Err(err))
+				status, resp := response.StorageError(err, "failed to get stats, internal error")
+				render.Status(r, status)
+				render.JSON(w, r, resp)
+				return
+			}
+			if owner != managed {
+				log.Info("not an owner of url", slog.String("alias", alias), slog.String("principal", principal.Name))
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, response.Error(response.CodeForbidden, "only the owner can read the stats of this URL"))
+				return
+			}
+		}
+
+		stats, err := statsGetter.GetStats(r.Context(), alias)
+		if err != nil {
+			if errors.Is(err, storage.ErrUrlNotFound) {
+				log.Info("url not found", slog.String("alias", alias))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error(response.CodeNotFound, "URL not found"))
+				return
+			}
+			log.Error("failed to get stats", slog.String("alias", alias), my_slog.Err(err))
+			status, resp := response.StorageError(err, "failed to get stats, internal error")
+			render.Status(r, status)
+			render.JSON(w, r, resp)
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			Alias:    alias,
+			Stats:    stats,
+		})
+	}
+}
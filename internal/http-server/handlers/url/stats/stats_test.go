@@ -0,0 +1,102 @@
+package stats_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"url-shortener/internal/http-server/handlers/url/stats"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockStatsGetter struct {
+	mock.Mock
+}
+
+func (m *MockStatsGetter) GetURLOwner(_ context.Context, alias string) (string, error) {
+	args := m.Called(alias)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStatsGetter) GetStats(_ context.Context, alias string) (storage.Stats, error) {
+	args := m.Called(alias)
+	return args.Get(0).(storage.Stats), args.Error(1)
+}
+
+func TestStatsHandler(t *testing.T) {
+	alice := auth.Principal{Name: "alice", Scopes: []string{auth.ScopeReadStats}}
+	bob := auth.Principal{Name: "bob", Scopes: []string{auth.ScopeReadStats}}
+	admin := auth.Principal{Name: auth.AdminName, Scopes: []string{auth.ScopeAdmin}}
+
+	cases := []struct {
+		name       string
+		alias      string
+		principal  auth.Principal
+		owner      string
+		ownerErr   error
+		wantStats  bool
+		wantStatus int
+	}{
+		{
+			name:       "Owner",
+			alias:      "ex",
+			principal:  alice,
+			owner:      "alice",
+			wantStats:  true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Other Key Forbidden",
+			alias:      "ex",
+			principal:  bob,
+			owner:      "alice",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Admin Reads Everything",
+			alias:      "ex",
+			principal:  admin,
+			wantStats:  true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Not Found",
+			alias:      "missing",
+			principal:  bob,
+			ownerErr:   storage.ErrUrlNotFound,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			getter := new(MockStatsGetter)
+			if tc.principal.ManagedOwner() != "" {
+				getter.On("GetURLOwner", tc.alias).Return(tc.owner, tc.ownerErr).Once()
+			}
+			if tc.wantStats {
+				getter.On("GetStats", tc.alias).Return(storage.Stats{TotalClicks: 3}, nil).Once()
+			}
+
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			r := chi.NewRouter()
+			r.Get("/url/{alias}/stats", stats.New(logger, getter))
+
+			req := httptest.NewRequest(http.MethodGet, "/url/"+tc.alias+"/stats", nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), tc.principal))
+			rr := httptest.NewRecorder()
+
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code)
+			getter.AssertExpectations(t)
+		})
+	}
+}
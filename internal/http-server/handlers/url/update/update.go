@@ -0,0 +1,88 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/api/validate"
+	my_slog "url-shortener/internal/lib/logger/my_slog"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type UrlUpdater interface {
+	UpdateURL(ctx context.Context, alias string, newURL string, owner string) error
+}
+
+// New retargets an existing alias in place, so that its creation metadata and stats survive.
+func New(log *slog.Logger, urlUpdater UrlUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.url.update.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			tracing.TraceID(r.Context()),
+		)
+
+		alias := chi.URLParam(r, "alias")
+
+		if alias == "" {
+			log.Info("alias is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeInvalidRequest, "invalid request"))
+			return
+		}
+
+		var req storage.UpdateRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to decode request body", my_slog.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(response.CodeInvalidRequest, "invalid request body"))
+			return
+		}
+
+		if err := validate.New().Struct(req); err != nil {
+			log.Error("request validation failed", my_slog.Err(err))
+			render.Status(r, http.StatusUnprocessableEntity)
+			render.JSON(w, r, response.ValidationError("validation failed: invalid url format or missing required fields", err))
+			return
+		}
+
+		//only the creator of a link or an admin may retarget it
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		owner := principal.ManagedOwner()
+
+		err := urlUpdater.UpdateURL(r.Context(), alias, req.URL, owner)
+		if errors.Is(err, storage.ErrUrlNotFound) {
+			log.Info("url not found", slog.String("alias", alias))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error(response.CodeNotFound, "URL not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrNotOwner) {
+			log.Info("not an owner of url", slog.String("alias", alias), slog.String("principal", principal.Name))
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, response.Error(response.CodeForbidden, "only the owner can update this URL"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to update url", slog.String("alias", alias), my_slog.Err(err))
+			status, resp := response.StorageError(err, "internal error")
+			render.Status(r, status)
+			render.JSON(w, r, resp)
+			return
+		}
+
+		log.Info("url updated", slog.String("alias", alias), slog.String("url", req.URL))
+
+		storage.ResponseOK(w, r, alias)
+	}
+}
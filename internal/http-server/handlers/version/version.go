@@ -0,0 +1,23 @@
+package version
+
+import (
+	"net/http"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/buildinfo"
+
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	buildinfo.Info
+}
+
+func New(info buildinfo.Info) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			Info:     info,
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/apikey"
+	my_slog "url-shortener/internal/lib/logger/my_slog"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	ScopeCreate    = "create"
+	ScopeDelete    = "delete"
+	ScopeReadStats = "read-stats"
+	// ScopeAdmin manages api keys and implies every other scope.
+	ScopeAdmin = "admin"
+)
+
+// AdminName is the principal name of the BasicAuth admin credential from config.
+const AdminName = "admin"
+
+var Scopes = []string{ScopeCreate, ScopeDelete, ScopeReadStats, ScopeAdmin}
+
+type APIKeyGetter interface {
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (storage.APIKey, error)
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Name   string
+	Scopes []string
+}
+
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Scopes, scope)
+}
+
+// ManagedOwner is the owner of the links p may modify: only its own, or any
+// when p is an admin, in which case it is empty.
+func (p Principal) ManagedOwner() string {
+	if p.HasScope(ScopeAdmin) {
+		return ""
+	}
+	return p.Name
+}
+
+type ctxKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
+
+// New authenticates requests by an "Authorization: Bearer <api key>" header.
+// The admin user/password pair from config is still accepted as BasicAuth,
+// so that the first api keys can be created.
+func New(log *slog.Logger, keyGetter APIKeyGetter, adminUser string, adminPassword string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		log.Info("auth middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			log := log.With(
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+				tracing.TraceID(r.Context()),
+			)
+
+			if _, _, ok := r.BasicAuth(); ok {
+				if isAdmin(r, adminUser, adminPassword) {
+					p := Principal{Name: AdminName, Scopes: []string{ScopeAdmin}}
+					next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+					return
+				}
+
+				log.Info("invalid basic auth credentials")
+				unauthorized(w, r)
+				return
+			}
+
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" {
+				unauthorized(w, r)
+				return
+			}
+
+			key, err := keyGetter.GetAPIKeyByHash(r.Context(), apikey.Hash(token))
+			if err != nil {
+				if !errors.Is(err, storage.ErrAPIKeyNotFound) {
+					log.Error("failed to get api key", my_slog.Err(err))
+					status, resp := response.StorageError(err, "internal error")
+					render.Status(r, status)
+					render.JSON(w, r, resp)
+					return
+				}
+
+				log.Info("unknown or revoked api key")
+				unauthorized(w, r)
+				return
+			}
+
+			p := Principal{Name: key.Name, Scopes: key.Scopes}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Basic accepts only the admin user/password pair from config, without api keys.
+func Basic(adminUser string, adminPassword string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !isAdmin(r, adminUser, adminPassword) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="url-shortener"`)
+				render.Status(r, http.StatusUnauthorized)
+				render.JSON(w, r, response.Error(response.CodeUnauthorized, "unauthorized"))
+				return
+			}
+
+			p := Principal{Name: AdminName, Scopes: []string{ScopeAdmin}}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireScope rejects requests whose principal lacks scope. It must run after New.
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				unauthorized(w, r)
+				return
+			}
+
+			if !p.HasScope(scope) {
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, response.Error(response.CodeForbidden, "missing scope: "+scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func isAdmin(r *http.Request, adminUser string, adminPassword string) bool {
+	user, password, ok := r.BasicAuth()
+
+	return ok && adminUser != "" &&
+		subtle.ConstantTimeCompare([]byte(user), []byte(adminUser)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(adminPassword)) == 1
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="url-shortener"`)
+	render.Status(r, http.StatusUnauthorized)
+	render.JSON(w, r, response.Error(response.CodeUnauthorized, "unauthorized"))
+}
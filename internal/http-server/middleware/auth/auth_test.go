@@ -0,0 +1,104 @@
+package auth_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAPIKeyGetter struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyGetter) GetAPIKeyByHash(_ context.Context, keyHash string) (storage.APIKey, error) {
+	args := m.Called(keyHash)
+	return args.Get(0).(storage.APIKey), args.Error(1)
+}
+
+func TestAuth(t *testing.T) {
+	const key = "us_valid"
+
+	cases := []struct {
+		name       string
+		setHeader  func(r *http.Request)
+		scope      string
+		wantStatus int
+		wantName   string
+	}{
+		{
+			name:       "No Credentials",
+			setHeader:  func(r *http.Request) {},
+			scope:      auth.ScopeCreate,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Admin Basic Auth",
+			setHeader:  func(r *http.Request) { r.SetBasicAuth("admin", "password123") },
+			scope:      auth.ScopeDelete,
+			wantStatus: http.StatusOK,
+			wantName:   auth.AdminName,
+		},
+		{
+			name:       "Wrong Basic Auth",
+			setHeader:  func(r *http.Request) { r.SetBasicAuth("admin", "wrong") },
+			scope:      auth.ScopeCreate,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Valid Key",
+			setHeader:  func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+key) },
+			scope:      auth.ScopeCreate,
+			wantStatus: http.StatusOK,
+			wantName:   "ci",
+		},
+		{
+			name:       "Valid Key Missing Scope",
+			setHeader:  func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+key) },
+			scope:      auth.ScopeDelete,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Unknown Key",
+			setHeader:  func(r *http.Request) { r.Header.Set("Authorization", "Bearer us_unknown") },
+			scope:      auth.ScopeCreate,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyGetter := new(MockAPIKeyGetter)
+			keyGetter.On("GetAPIKeyByHash", apikey.Hash(key)).
+				Return(storage.APIKey{ID: 1, Name: "ci", Scopes: []string{auth.ScopeCreate}}, nil)
+			keyGetter.On("GetAPIKeyByHash", mock.Anything).
+				Return(storage.APIKey{}, storage.ErrAPIKeyNotFound)
+
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+			var gotName string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				p, _ := auth.PrincipalFromContext(r.Context())
+				gotName = p.Name
+			})
+			handler := auth.New(logger, keyGetter, "admin", "password123")(auth.RequireScope(tc.scope)(next))
+
+			req := httptest.NewRequest(http.MethodPost, "/url", nil)
+			tc.setHeader(req)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code)
+			require.Equal(t, tc.wantName, gotName)
+		})
+	}
+}
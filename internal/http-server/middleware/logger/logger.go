@@ -2,13 +2,41 @@ package logger
 
 import (
 	"net/http"
+	"slices"
+	"strings"
 	"time"
+	"url-shortener/internal/lib/tracing"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"log/slog"
 )
 
-func New(log *slog.Logger) func(next http.Handler) http.Handler {
+// Unmatched is the route of requests no route matched, so that scanners probing
+// random paths cannot blow up the number of metric series. None of our routes has
+// a wildcard, so a pattern ending in one is a subrouter that found no route.
+const Unmatched = "unmatched"
+
+// Completed is a request New has served.
+type Completed struct {
+	*http.Request
+	Route    string //route pattern, or Unmatched
+	Status   int
+	Bytes    int
+	Duration time.Duration
+}
+
+// Observer is told about every completed request, logged or not.
+type Observer func(c Completed)
+
+type Options struct {
+	// Quiet are the routes that are not logged, e.g. probes polled every few seconds.
+	Quiet []string
+	// Observers are called in order once a request is completed.
+	Observers []Observer
+}
+
+func New(log *slog.Logger, opts Options) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		log := log.With(
 			slog.String("component", "middleware/logger"),
@@ -17,21 +45,39 @@ func New(log *slog.Logger) func(next http.Handler) http.Handler {
 		log.Info("logger middleware enabled")
 
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			entry := log.With(
-				slog.String("method", r.Method),
-				slog.String("path", r.URL.Path),
-				slog.String("remote_addr", r.RemoteAddr),
-				slog.String("user_agent", r.UserAgent()),
-				slog.String("request_id", middleware.GetReqID(r.Context())),
-			)
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
 			t1 := time.Now()
 			defer func() {
-				entry.Info("request completed",
-					slog.Int("status", ww.Status()),
-					slog.Int("bytes", ww.BytesWritten()),
-					slog.String("duration", time.Since(t1).String()),
+				c := Completed{
+					Request:  r,
+					Route:    route(r),
+					Status:   ww.Status(),
+					Bytes:    ww.BytesWritten(),
+					Duration: time.Since(t1),
+				}
+				if c.Status == 0 {
+					//nothing written, net/http replies 200
+					c.Status = http.StatusOK
+				}
+
+				for _, observe := range opts.Observers {
+					observe(c)
+				}
+
+				if slices.Contains(opts.Quiet, c.Route) {
+					return
+				}
+				log.Info("request completed",
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("remote_addr", r.RemoteAddr),
+					slog.String("user_agent", r.UserAgent()),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					tracing.TraceID(r.Context()),
+					slog.Int("status", c.Status),
+					slog.Int("bytes", c.Bytes),
+					slog.String("duration", c.Duration.String()),
 				)
 			}()
 
@@ -41,3 +87,13 @@ func New(log *slog.Logger) func(next http.Handler) http.Handler {
 		return http.HandlerFunc(fn)
 	}
 }
+
+// route returns the pattern of the route that served r, once it is served.
+func route(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" && !strings.HasSuffix(pattern, "*") {
+			return pattern
+		}
+	}
+	return Unmatched
+}
@@ -0,0 +1,16 @@
+package metrics
+
+import (
+	"strconv"
+	mw_logger "url-shortener/internal/http-server/middleware/logger"
+	"url-shortener/internal/lib/metrics"
+)
+
+// Observe counts requests and measures their latency per route pattern and status,
+// as completed by the logger middleware.
+func Observe(c mw_logger.Completed) {
+	labels := []string{c.Route, c.Method, strconv.Itoa(c.Status)}
+
+	metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+	metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(c.Duration.Seconds())
+}
@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"net/http"
+	mw_logger "url-shortener/internal/http-server/middleware/logger"
+	"url-shortener/internal/lib/tracing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New starts a server span for every request, continuing the trace of the
+// traceparent header if there is one. Observe completes the span.
+func New() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracing.Tracer().Start(ctx, r.Method,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("url.path", r.URL.Path),
+					attribute.String("request_id", middleware.GetReqID(r.Context())),
+				),
+			)
+			defer span.End()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Observe names the span of a request completed by the logger middleware after
+// its route pattern, and records its status.
+func Observe(c mw_logger.Completed) {
+	span := trace.SpanFromContext(c.Context())
+	if c.Route != mw_logger.Unmatched {
+		span.SetName(c.Method + " " + c.Route)
+		span.SetAttributes(attribute.String("http.route", c.Route))
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", c.Status))
+	if c.Status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(c.Status))
+	}
+}
@@ -0,0 +1,119 @@
+// Package router wires the handlers of the service to their routes.
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"url-shortener/internal/http-server/handlers/apikey/create"
+	"url-shortener/internal/http-server/handlers/apikey/list"
+	"url-shortener/internal/http-server/handlers/apikey/revoke"
+	"url-shortener/internal/http-server/handlers/healthz"
+	"url-shortener/internal/http-server/handlers/openapi"
+	"url-shortener/internal/http-server/handlers/readyz"
+	"url-shortener/internal/http-server/handlers/redirect"
+	batchdelete "url-shortener/internal/http-server/handlers/url/batch/delete"
+	batchsave "url-shortener/internal/http-server/handlers/url/batch/save"
+	"url-shortener/internal/http-server/handlers/url/delete"
+	urllist "url-shortener/internal/http-server/handlers/url/list"
+	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/handlers/url/stats"
+	"url-shortener/internal/http-server/handlers/url/update"
+	"url-shortener/internal/http-server/handlers/version"
+	"url-shortener/internal/http-server/middleware/auth"
+	mw_logger "url-shortener/internal/http-server/middleware/logger"
+	mw_metrics "url-shortener/internal/http-server/middleware/metrics"
+	mw_tracing "url-shortener/internal/http-server/middleware/tracing"
+	"url-shortener/internal/lib/buildinfo"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Deps are everything the handlers need.
+type Deps struct {
+	Log     *slog.Logger
+	Storage storage.Store
+	// URLGetter resolves aliases for redirects, Storage if nil.
+	URLGetter      redirect.URLGetter
+	ClickRecorder  redirect.ClickRecorder
+	AliasGenerator save.AliasGenerator
+	// IDEncoder is set for the sequence alias strategy only.
+	IDEncoder   save.IDEncoder
+	AliasPolicy save.AliasValidator
+	// RedirectAuth and ManagementAuth authenticate the public and the management routes.
+	RedirectAuth   func(http.Handler) http.Handler
+	ManagementAuth func(http.Handler) http.Handler
+}
+
+// probes are the routes polled by orchestrators and scrapers.
+var probes = []string{"/healthz", "/readyz", "/version", "/metrics"}
+
+// New returns the router of the service. Every route must be described in the
+// OpenAPI document served at /openapi.json.
+func New(d Deps) chi.Router {
+	log := d.Log
+	urlGetter := d.URLGetter
+	if urlGetter == nil {
+		urlGetter = d.Storage
+	}
+
+	router := chi.NewRouter()
+
+	router.Use(middleware.RequestID) //add id to all requests
+	router.Use(mw_tracing.New())     //start a span per request, continuing the caller's trace
+	//log all requests but the probes, count and trace them per route, including recovered panics
+	router.Use(mw_logger.New(log, mw_logger.Options{
+		Quiet:     probes,
+		Observers: []mw_logger.Observer{mw_metrics.Observe, mw_tracing.Observe},
+	}))
+	router.Use(middleware.Recoverer) //recover from panics
+	//no middleware.URLFormat: it would route /openapi.json as /openapi, and no handler reads the format
+
+	//probes and metrics: polled every few seconds, so neither authenticated nor logged
+	router.Get("/healthz", healthz.New())
+	router.Get("/readyz", readyz.New(log, d.Storage, d.Storage.Migrator()))
+	router.Get("/version", version.New(buildinfo.Read()))
+	router.Method(http.MethodGet, "/metrics", metrics.Handler())
+
+	router.Group(func(router chi.Router) {
+		router.Use(middleware.Logger) //log all requests
+
+		router.Get("/openapi.json", openapi.New())
+
+		//public: short links must resolve for anyone who clicks them
+		router.Group(func(r chi.Router) {
+			r.Use(d.RedirectAuth)
+
+			r.Get("/{alias}", redirect.New(log, urlGetter, d.ClickRecorder))
+		})
+
+		//management api
+		router.Group(func(r chi.Router) {
+			r.Use(d.ManagementAuth)
+
+			r.Route("/url", func(r chi.Router) {
+				r.Get("/", urllist.New(log, d.Storage))
+				r.With(auth.RequireScope(auth.ScopeCreate)).Post("/", save.New(log, d.Storage, d.AliasGenerator, d.IDEncoder, d.AliasPolicy))
+				r.With(auth.RequireScope(auth.ScopeCreate)).Post("/batch", batchsave.New(log, d.Storage, d.AliasGenerator, d.IDEncoder, d.AliasPolicy))
+				r.With(auth.RequireScope(auth.ScopeDelete)).Delete("/batch", batchdelete.New(log, d.Storage))
+				r.With(auth.RequireScope(auth.ScopeReadStats)).Get("/{alias}/stats", stats.New(log, d.Storage))
+				r.With(auth.RequireScope(auth.ScopeCreate)).Patch("/{alias}", update.New(log, d.Storage))
+				r.With(auth.RequireScope(auth.ScopeDelete)).Delete("/{alias}", delete.New(log, d.Storage))
+			})
+			//deprecated, kept for clients of DELETE /{alias}
+			r.With(auth.RequireScope(auth.ScopeDelete)).Delete("/{alias}", delete.New(log, d.Storage))
+
+			r.Route("/admin/keys", func(r chi.Router) {
+				r.Use(auth.RequireScope(auth.ScopeAdmin))
+
+				r.Post("/", create.New(log, d.Storage))
+				r.Get("/", list.New(log, d.Storage))
+				r.Delete("/{id}", revoke.New(log, d.Storage))
+			})
+		})
+	})
+
+	return router
+}
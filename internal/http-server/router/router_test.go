@@ -0,0 +1,163 @@
+package router_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"url-shortener/internal/http-server/handlers/openapi"
+	"url-shortener/internal/http-server/router"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/instrumented"
+	"url-shortener/internal/storage/sqlite"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func passthrough(next http.Handler) http.Handler {
+	return next
+}
+
+func newRouter(t *testing.T, logs io.Writer) (chi.Router, storage.Store) {
+	db, err := sqlite.New(filepath.Join(t.TempDir(), "storage.db"), sqlite.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	store := instrumented.New(db, "sqlite")
+
+	return router.New(router.Deps{
+		Log:            slog.New(slog.NewJSONHandler(logs, nil)),
+		Storage:        store,
+		RedirectAuth:   passthrough,
+		ManagementAuth: passthrough,
+	}), store
+}
+
+// TestSpecMatchesRouter fails when a route is added without documenting it, or the other way round.
+func TestSpecMatchesRouter(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(openapi.Spec, &spec))
+
+	var documented []string
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	var routed []string
+	r, _ := newRouter(t, io.Discard)
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		routed = append(routed, method+" "+route)
+		return nil
+	})
+	require.NoError(t, err)
+
+	slices.Sort(documented)
+	slices.Sort(routed)
+	require.Equal(t, routed, documented)
+}
+
+func TestServeSpec(t *testing.T) {
+	r, _ := newRouter(t, io.Discard)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	require.JSONEq(t, string(openapi.Spec), rr.Body.String())
+}
+
+func TestProbes(t *testing.T) {
+	var logs bytes.Buffer
+	r, store := newRouter(t, &logs)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	require.Equal(t, http.StatusOK, get("/healthz").Code)
+
+	rr := get("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.Contains(t, rr.Body.String(), `"code":"not_ready"`)
+	require.Contains(t, rr.Body.String(), "migrations are pending")
+
+	_, err := store.Migrator().Up(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, get("/readyz").Code)
+
+	rr = get("/version")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"go_version":"go`)
+
+	get("/url/some-alias/stats/extra")
+	rr = get("/metrics")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `url_shortener_http_requests_total{method="GET",route="/healthz",status="200"} 1`)
+	require.Contains(t, rr.Body.String(), `url_shortener_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+
+	//probes are counted but not logged
+	require.NotContains(t, logs.String(), `"path":"/healthz"`)
+	require.Contains(t, logs.String(), `"path":"/url/some-alias/stats/extra"`)
+}
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	var logs bytes.Buffer
+	r, store := newRouter(t, &logs)
+	_, err := store.Migrator().Up(context.Background())
+	require.NoError(t, err)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/missing-alias", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	//children end first
+	db, server := spans[0], spans[1]
+	require.Equal(t, "storage.sqlite.GetURL", db.Name)
+	require.Equal(t, "GET /{alias}", server.Name)
+	require.Equal(t, traceID, server.SpanContext.TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+	require.Equal(t, server.SpanContext.SpanID(), db.Parent.SpanID())
+
+	require.Contains(t, logs.String(), `"msg":"request completed"`)
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if strings.Contains(line, `"request_id"`) {
+			require.Contains(t, line, `"trace_id":"`+traceID+`"`)
+		}
+	}
+}
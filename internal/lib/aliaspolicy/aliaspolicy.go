@@ -0,0 +1,133 @@
+// Package aliaspolicy decides which custom aliases clients may choose.
+package aliaspolicy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultCharset keeps aliases safe to use as a url path segment.
+const DefaultCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_"
+
+// DefaultReserved are the top-level paths of the service itself.
+var DefaultReserved = []string{"url", "admin", "api", "healthz", "readyz", "version", "metrics", "docs", "openapi.json", "openapi.yaml", "static", "favicon.ico", "robots.txt"}
+
+var ErrInvalidAlias = errors.New("invalid alias")
+
+type Options struct {
+	Charset   string
+	MinLength int
+	MaxLength int
+	// CaseSensitive makes reserved and blocked words match only in the exact case,
+	// so that e.g. "URL" is allowed while "url" is not.
+	CaseSensitive bool
+	Reserved      []string
+	// Blocked words may not appear anywhere in an alias.
+	Blocked []string
+}
+
+type Policy struct {
+	allowed       [utf8.RuneSelf]bool
+	minLength     int
+	maxLength     int
+	caseSensitive bool
+	reserved      map[string]struct{}
+	blocked       []string
+}
+
+func New(opts Options) (*Policy, error) {
+	if opts.Charset == "" {
+		opts.Charset = DefaultCharset
+	}
+	if opts.MinLength < 1 || opts.MaxLength < opts.MinLength {
+		return nil, fmt.Errorf("aliaspolicy: invalid length range %d-%d", opts.MinLength, opts.MaxLength)
+	}
+
+	p := &Policy{
+		minLength:     opts.MinLength,
+		maxLength:     opts.MaxLength,
+		caseSensitive: opts.CaseSensitive,
+		reserved:      make(map[string]struct{}, len(opts.Reserved)),
+	}
+
+	for _, ch := range opts.Charset {
+		//'/', '?', '#' and '%' would change the meaning of the short url
+		if ch >= utf8.RuneSelf || ch <= ' ' || strings.ContainsRune("/?#%", ch) {
+			return nil, fmt.Errorf("aliaspolicy: character %q is not allowed in the charset", ch)
+		}
+		p.allowed[ch] = true
+	}
+	for _, word := range opts.Reserved {
+		p.reserved[p.fold(word)] = struct{}{}
+	}
+	for _, word := range opts.Blocked {
+		if word != "" {
+			p.blocked = append(p.blocked, p.fold(word))
+		}
+	}
+
+	return p, nil
+}
+
+// Validate returns an error wrapping ErrInvalidAlias that tells the client what is wrong with alias.
+func (p *Policy) Validate(alias string) error {
+	if n := utf8.RuneCountInString(alias); n < p.minLength || n > p.maxLength {
+		return fmt.Errorf("%w: must be %d to %d characters long", ErrInvalidAlias, p.minLength, p.maxLength)
+	}
+
+	for _, ch := range alias {
+		if ch >= utf8.RuneSelf || !p.allowed[ch] {
+			return fmt.Errorf("%w: character %q is not allowed", ErrInvalidAlias, ch)
+		}
+	}
+
+	folded := p.fold(alias)
+	if _, ok := p.reserved[folded]; ok {
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, alias)
+	}
+	for _, word := range p.blocked {
+		if strings.Contains(folded, word) {
+			//the blocked word itself is not echoed back
+			return fmt.Errorf("%w: contains a blocked word", ErrInvalidAlias)
+		}
+	}
+
+	return nil
+}
+
+func (p *Policy) fold(s string) string {
+	if p.caseSensitive {
+		return s
+	}
+	return strings.ToLower(s)
+}
+
+// LoadBlocklist reads one word per line from path. Blank lines and lines starting with # are skipped.
+func LoadBlocklist(path string) ([]string, error) {
+	const op = "lib.aliaspolicy.LoadBlocklist"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	var words []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		word := strings.TrimSpace(sc.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return words, nil
+}
@@ -0,0 +1,100 @@
+package aliaspolicy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  Options
+		alias string
+		err   string
+	}{
+		{
+			name:  "valid",
+			alias: "my-link_1",
+		},
+		{
+			name:  "too short",
+			alias: "ab",
+			err:   "invalid alias: must be 3 to 10 characters long",
+		},
+		{
+			name:  "too long",
+			alias: "abcdefghijk",
+			err:   "invalid alias: must be 3 to 10 characters long",
+		},
+		{
+			name:  "slash",
+			alias: "a/b/c",
+			err:   "invalid alias: character '/' is not allowed",
+		},
+		{
+			name:  "emoji",
+			alias: "link🙂",
+			err:   "invalid alias: character '🙂' is not allowed",
+		},
+		{
+			name:  "custom charset",
+			opts:  Options{Charset: "abc"},
+			alias: "abcd",
+			err:   "invalid alias: character 'd' is not allowed",
+		},
+		{
+			name:  "reserved",
+			alias: "healthz",
+			err:   `invalid alias: "healthz" is reserved`,
+		},
+		{
+			name:  "reserved in other case",
+			alias: "URL",
+			err:   `invalid alias: "URL" is reserved`,
+		},
+		{
+			name:  "reserved case sensitive",
+			opts:  Options{CaseSensitive: true},
+			alias: "URL",
+		},
+		{
+			name:  "blocked",
+			alias: "myBadWord",
+			err:   "invalid alias: contains a blocked word",
+		},
+		{
+			name:  "blocked case sensitive",
+			opts:  Options{CaseSensitive: true},
+			alias: "myBadWord",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.MinLength, tt.opts.MaxLength = 3, 10
+			tt.opts.Reserved = DefaultReserved
+			tt.opts.Blocked = []string{"badword"}
+
+			p, err := New(tt.opts)
+			require.NoError(t, err)
+
+			err = p.Validate(tt.alias)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidAlias)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(Options{MinLength: 0, MaxLength: 10})
+	assert.Error(t, err)
+	_, err = New(Options{MinLength: 5, MaxLength: 4})
+	assert.Error(t, err)
+	_, err = New(Options{Charset: "ab/", MinLength: 1, MaxLength: 4})
+	assert.Error(t, err)
+}
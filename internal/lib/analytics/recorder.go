@@ -0,0 +1,87 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	my_slog "url-shortener/internal/lib/logger/my_slog"
+	"url-shortener/internal/storage"
+)
+
+type ClickSaver interface {
+	SaveClicks(ctx context.Context, clicks []storage.Click) error
+}
+
+// Recorder buffers click events in memory and writes them to storage in batches,
+// so that the redirect hot path never waits on the database.
+type Recorder struct {
+	log           *slog.Logger
+	saver         ClickSaver
+	clicks        chan storage.Click
+	batchSize     int
+	flushInterval time.Duration
+}
+
+func New(log *slog.Logger, saver ClickSaver, bufferSize int, batchSize int, flushInterval time.Duration) *Recorder {
+	return &Recorder{
+		log:           log.With(slog.String("component", "analytics/recorder")),
+		saver:         saver,
+		clicks:        make(chan storage.Click, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+	}
+}
+
+// Record enqueues a click without blocking. The click is dropped if the buffer is full.
+func (r *Recorder) Record(click storage.Click) {
+	select {
+	case r.clicks <- click:
+	default:
+		r.log.Warn("click buffer is full, dropping click", slog.String("alias", click.Alias))
+	}
+}
+
+// Run writes buffered clicks until ctx is cancelled, then flushes whatever is left.
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]storage.Click, 0, r.batchSize)
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case click := <-r.clicks:
+					batch = append(batch, click)
+				default:
+					//the final flush must not be cancelled along with the recorder
+					r.flush(context.WithoutCancel(ctx), batch)
+					return
+				}
+			}
+		case click := <-r.clicks:
+			batch = append(batch, click)
+			if len(batch) >= r.batchSize {
+				batch = r.flush(ctx, batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(ctx, batch)
+		}
+	}
+}
+
+// flush saves the batch and returns it emptied for reuse.
+func (r *Recorder) flush(ctx context.Context, batch []storage.Click) []storage.Click {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := r.saver.SaveClicks(ctx, batch); err != nil {
+		r.log.Error("failed to save clicks", slog.Int("count", len(batch)), my_slog.Err(err))
+	}
+
+	return batch[:0]
+}
@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClickSaver struct {
+	mu      sync.Mutex
+	batches [][]storage.Click
+}
+
+func (f *fakeClickSaver) SaveClicks(_ context.Context, clicks []storage.Click) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, append([]storage.Click(nil), clicks...))
+	return nil
+}
+
+func (f *fakeClickSaver) saved() (batches int, clicks int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, b := range f.batches {
+		clicks += len(b)
+	}
+	return len(f.batches), clicks
+}
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name          string
+		clicks        int
+		batchSize     int
+		flushInterval time.Duration
+		wantBatches   int
+	}{
+		{
+			name:          "flush by batch size",
+			clicks:        10,
+			batchSize:     5,
+			flushInterval: time.Hour,
+			wantBatches:   2,
+		},
+		{
+			name:          "flush by interval",
+			clicks:        3,
+			batchSize:     100,
+			flushInterval: 10 * time.Millisecond,
+			wantBatches:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saver := &fakeClickSaver{}
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			rec := New(logger, saver, 100, tt.batchSize, tt.flushInterval)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go rec.Run(ctx)
+
+			for i := 0; i < tt.clicks; i++ {
+				rec.Record(storage.Click{Alias: "alias", ClickedAt: time.Now()})
+			}
+
+			require.Eventually(t, func() bool {
+				batches, clicks := saver.saved()
+				return batches == tt.wantBatches && clicks == tt.clicks
+			}, time.Second, 5*time.Millisecond)
+		})
+	}
+}
+
+func TestRecorder_FlushOnStop(t *testing.T) {
+	saver := &fakeClickSaver{}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	rec := New(logger, saver, 100, 100, time.Hour)
+
+	for i := 0; i < 7; i++ {
+		rec.Record(storage.Click{Alias: "alias", ClickedAt: time.Now()})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec.Run(ctx)
+
+	_, clicks := saver.saved()
+	require.Equal(t, 7, clicks)
+}
@@ -1,25 +1,122 @@
 package response
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+)
+
 type Response struct {
-	Status string      `json:"status"`           // "OK" or "Error"
-	Error  string      `json:"error,omitempty"`  // omitempty to not return empty field
+	Status string `json:"status"`          // "OK" or "Error"
+	Code   string `json:"code,omitempty"`  // machine-readable reason of an error, one of the Code constants
+	Error  string `json:"error,omitempty"` // omitempty to not return empty field
+	// Fields lists the invalid fields of a request that failed validation.
+	Fields []FieldError `json:"fields,omitempty"`
 }
 
+// FieldError is a failed validation rule of a request field.
+type FieldError struct {
+	Field   string `json:"field"` // json name of the field
+	Rule    string `json:"rule"`  // validator tag, e.g. "required" or "url"
+	Message string `json:"message"`
+}
 
 const (
 	StatusOK    = "OK"
 	StatusError = "Error"
 )
 
+// Error codes. Clients should branch on these instead of the error text.
+const (
+	CodeInvalidRequest    = "invalid_request" // malformed body, path or query
+	CodeValidationFailed  = "validation_failed"
+	CodeInvalidURL        = "invalid_url"
+	CodeInvalidAlias      = "invalid_alias"
+	CodeInvalidExpiration = "invalid_expiration"
+	CodeAliasTaken        = "alias_taken"
+	CodeNameTaken         = "name_taken"
+	CodeNotFound          = "not_found"
+	CodeExpired           = "expired"
+	CodeUnauthorized      = "unauthorized"
+	CodeForbidden         = "forbidden"
+	CodeTooLarge          = "too_large"
+	CodeNotReady          = "not_ready" // the service cannot serve requests yet
+	CodeTimeout           = "timeout"   // the storage did not answer in time
+	CodeInternal          = "internal_error"
+)
+
+// StatusClientClosedRequest is recorded for requests whose client went away
+// before the answer, like nginx does. The client never receives it.
+const StatusClientClosedRequest = 499
+
 func OK() Response {
 	return Response{
 		Status: StatusOK,
 	}
 }
 
-func Error(errMsg string) Response {
+func Error(code string, errMsg string) Response {
 	return Response{
 		Status: StatusError,
+		Code:   code,
 		Error:  errMsg,
 	}
-}
\ No newline at end of file
+}
+
+// StorageError returns the status and response of a storage operation that failed
+// with err: a timeout when its deadline passed, an internal error with errMsg otherwise.
+func StorageError(err error, errMsg string) (int, Response) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest, Error(CodeInternal, "request canceled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable, Error(CodeTimeout, "storage timeout, try again later")
+	}
+
+	return http.StatusInternalServerError, Error(CodeInternal, errMsg)
+}
+
+// ValidationError describes err returned by a validator from validate.New.
+// A failed url rule is reported as CodeInvalidURL, anything else as CodeValidationFailed.
+func ValidationError(errMsg string, err error) Response {
+	resp := Error(CodeValidationFailed, errMsg)
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return resp
+	}
+
+	for _, fe := range verrs {
+		resp.Fields = append(resp.Fields, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fieldMessage(fe),
+		})
+	}
+	if len(resp.Fields) == 1 && resp.Fields[0].Rule == "url" {
+		resp.Code = CodeInvalidURL
+	}
+
+	return resp
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "url":
+		return "must be an absolute url"
+	case "excluded_with":
+		return "cannot be combined with " + fe.Param()
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	case "min":
+		return "must have at least " + fe.Param() + " items"
+	case "max":
+		return "must be at most " + fe.Param() + " characters long"
+	}
+	return fmt.Sprintf("failed the %q rule", fe.Tag())
+}
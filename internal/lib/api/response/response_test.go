@@ -0,0 +1,95 @@
+package response_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/api/validate"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type request struct {
+	URL       string     `json:"url" validate:"required,url"`
+	TTL       string     `json:"ttl,omitempty" validate:"omitempty,excluded_with=ExpiresAt"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func TestValidationError(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		req    request
+		code   string
+		fields []response.FieldError
+	}{
+		{
+			name: "invalid url",
+			req:  request{URL: "not a url"},
+			code: response.CodeInvalidURL,
+			fields: []response.FieldError{
+				{Field: "url", Rule: "url", Message: "must be an absolute url"},
+			},
+		},
+		{
+			name: "several fields",
+			req:  request{TTL: "1h", ExpiresAt: &now},
+			code: response.CodeValidationFailed,
+			fields: []response.FieldError{
+				{Field: "url", Rule: "required", Message: "is required"},
+				{Field: "ttl", Rule: "excluded_with", Message: "cannot be combined with ExpiresAt"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate.New().Struct(tt.req)
+
+			resp := response.ValidationError("validation failed", err)
+			assert.Equal(t, response.StatusError, resp.Status)
+			assert.Equal(t, tt.code, resp.Code)
+			assert.Equal(t, tt.fields, resp.Fields)
+		})
+	}
+}
+
+func TestStorageError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{
+			name:   "deadline exceeded",
+			err:    fmt.Errorf("storage.sqlite.GetURL: %w", context.DeadlineExceeded),
+			status: http.StatusServiceUnavailable,
+			code:   response.CodeTimeout,
+		},
+		{
+			name:   "client gone",
+			err:    fmt.Errorf("storage.sqlite.GetURL: %w", context.Canceled),
+			status: response.StatusClientClosedRequest,
+			code:   response.CodeInternal,
+		},
+		{
+			name:   "other",
+			err:    errors.New("disk I/O error"),
+			status: http.StatusInternalServerError,
+			code:   response.CodeInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := response.StorageError(tt.err, "failed to get URL, internal error")
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, response.StatusError, resp.Status)
+			assert.Equal(t, tt.code, resp.Code)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	prefix   = "us_"
+	keyBytes = 32
+)
+
+// Generate returns a new random plaintext key. It is shown to the caller once
+// and only its Hash is stored.
+func Generate() (string, error) {
+	const op = "lib.apikey.Generate"
+
+	b := make([]byte, keyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash is the value persisted in storage and used for lookups.
+// Keys carry 256 bits of entropy, so a plain sha256 is enough.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,46 @@
+// Package buildinfo describes the running binary.
+package buildinfo
+
+import (
+	"runtime/debug"
+)
+
+// BuildTime is set at link time, as the vcs metadata only has the time of the commit:
+//
+//	go build -ldflags "-X url-shortener/internal/lib/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var BuildTime string
+
+type Info struct {
+	Version    string `json:"version"` // module version, "(devel)" for local builds
+	Commit     string `json:"commit,omitempty"`
+	CommitTime string `json:"commit_time,omitempty"`
+	Modified   bool   `json:"modified"` // built from a dirty work tree
+	BuildTime  string `json:"build_time,omitempty"`
+	GoVersion  string `json:"go_version"`
+}
+
+// Read returns the info embedded by the go toolchain. Binaries built outside a
+// git checkout, and test binaries, have no commit.
+func Read() Info {
+	info := Info{BuildTime: BuildTime}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.Version = bi.Main.Version
+	info.GoVersion = bi.GoVersion
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Commit = s.Value
+		case "vcs.time":
+			info.CommitTime = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+
+	return info
+}
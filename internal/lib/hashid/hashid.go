@@ -0,0 +1,137 @@
+package hashid
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math"
+	"math/rand/v2"
+)
+
+const (
+	alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	base     = len(alphabet)
+
+	// MaxMinLength keeps the length offset within uint64.
+	MaxMinLength = 10
+
+	// maxLength is the number of digits of math.MaxUint64.
+	maxLength = 11
+)
+
+// Codec maps row ids to aliases and back. The alphabet is shuffled by the salt,
+// and every digit is mixed with the less significant ones, so aliases do not
+// reveal ids or their order at a glance. They are not secret though: the
+// mapping is a fixed permutation that enough aliases give away.
+type Codec struct {
+	alphabet string
+	index    [256]int
+	// sbox and key are the salted substitution of the digit mixing
+	sbox [base]int
+	key  int
+	// offset is added to every id so that all aliases have at least minLength characters
+	offset uint64
+}
+
+func New(salt string, minLength int) (*Codec, error) {
+	if minLength < 1 || minLength > MaxMinLength {
+		return nil, errors.New("hashid: min length must be between 1 and 10")
+	}
+
+	seed := sha256.Sum256([]byte(salt))
+	rnd := rand.New(rand.NewChaCha8(seed))
+
+	shuffled := []byte(alphabet)
+	rnd.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+	c := &Codec{alphabet: string(shuffled), key: rnd.IntN(base), offset: 1}
+	for i := range c.index {
+		c.index[i] = -1
+	}
+	for i, ch := range shuffled {
+		c.index[ch] = i
+	}
+	copy(c.sbox[:], rnd.Perm(base))
+	for i := 1; i < minLength; i++ {
+		c.offset *= uint64(base)
+	}
+
+	return c, nil
+}
+
+func (c *Codec) Encode(id int64) string {
+	n := uint64(id) + c.offset
+
+	var digits [maxLength]int
+	i := len(digits)
+	for n > 0 {
+		i--
+		digits[i] = int(n % uint64(base))
+		n /= uint64(base)
+	}
+	c.mix(digits[i:])
+
+	buf := make([]byte, 0, len(digits)-i)
+	for _, d := range digits[i:] {
+		buf = append(buf, c.alphabet[d])
+	}
+
+	return string(buf)
+}
+
+// Decode returns the id encoded in alias, or false if alias is not a valid encoding.
+func (c *Codec) Decode(alias string) (int64, bool) {
+	if alias == "" || len(alias) > maxLength {
+		return 0, false
+	}
+
+	var buf [maxLength]int
+	digits := buf[:len(alias)]
+	for i := range digits {
+		if digits[i] = c.index[alias[i]]; digits[i] < 0 {
+			return 0, false
+		}
+	}
+	c.unmix(digits)
+
+	var n uint64
+	for i, d := range digits {
+		if i == 0 && d == 0 {
+			return 0, false
+		}
+		if n > (math.MaxUint64-uint64(d))/uint64(base) {
+			return 0, false
+		}
+		n = n*uint64(base) + uint64(d)
+	}
+
+	if n < c.offset || n-c.offset > math.MaxInt64 {
+		return 0, false
+	}
+
+	return int64(n - c.offset), true
+}
+
+// mix shifts every digit, from the least significant one up, by the substitution
+// of the mixed digit after it and its position. Consecutive ids differ in their
+// last digit, so the change reaches every digit of their aliases.
+func (c *Codec) mix(digits []int) {
+	prev := c.key
+	for i := len(digits) - 1; i >= 0; i-- {
+		digits[i] = (digits[i] + c.shift(prev, len(digits)-1-i)) % base
+		prev = digits[i]
+	}
+}
+
+// unmix reverts mix.
+func (c *Codec) unmix(digits []int) {
+	prev := c.key
+	for i := len(digits) - 1; i >= 0; i-- {
+		mixed := digits[i]
+		digits[i] = (digits[i] + base - c.shift(prev, len(digits)-1-i)) % base
+		prev = mixed
+	}
+}
+
+func (c *Codec) shift(prev int, pos int) int {
+	return c.sbox[(prev+pos)%base]
+}
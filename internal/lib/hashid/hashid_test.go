@@ -0,0 +1,101 @@
+package hashid
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec(t *testing.T) {
+	tests := []struct {
+		name      string
+		minLength int
+		ids       []int64
+	}{
+		{
+			name:      "min length 1",
+			minLength: 1,
+			ids:       []int64{0, 1, 61, 62, 1 << 40, math.MaxInt64},
+		},
+		{
+			name:      "min length 6",
+			minLength: 6,
+			ids:       []int64{0, 1, 62, 1 << 40, math.MaxInt64},
+		},
+		{
+			name:      "min length 10",
+			minLength: MaxMinLength,
+			ids:       []int64{0, 1, math.MaxInt64},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New("salt", tt.minLength)
+			require.NoError(t, err)
+
+			for _, id := range tt.ids {
+				alias := c.Encode(id)
+				assert.GreaterOrEqual(t, len(alias), tt.minLength)
+
+				got, ok := c.Decode(alias)
+				require.True(t, ok, alias)
+				assert.Equal(t, id, got)
+			}
+		})
+	}
+}
+
+func TestCodec_Salt(t *testing.T) {
+	a, err := New("a", 6)
+	require.NoError(t, err)
+	b, err := New("b", 6)
+	require.NoError(t, err)
+	a2, err := New("a", 6)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, a.Encode(1), b.Encode(1))
+	assert.Equal(t, a.Encode(1), a2.Encode(1))
+}
+
+func TestCodec_Invalid(t *testing.T) {
+	_, err := New("salt", 0)
+	assert.Error(t, err)
+	_, err = New("salt", MaxMinLength+1)
+	assert.Error(t, err)
+
+	c, err := New("salt", 6)
+	require.NoError(t, err)
+
+	for _, alias := range []string{"", "abc", "ab-cdef", "zzzzzzzzzzzz"} {
+		_, ok := c.Decode(alias)
+		assert.False(t, ok, alias)
+	}
+
+	//one of the prefixes is a leading zero, the others are aliases of other ids
+	for i := 0; i < len(c.alphabet); i++ {
+		alias := c.alphabet[i:i+1] + c.Encode(1)
+		if id, ok := c.Decode(alias); ok {
+			assert.Equal(t, alias, c.Encode(id))
+		}
+	}
+}
+
+func TestCodec_Neighbours(t *testing.T) {
+	c, err := New("salt", 6)
+	require.NoError(t, err)
+
+	prev := c.Encode(1000)
+	ordered := true
+	for id := int64(1001); id < 1100; id++ {
+		alias := c.Encode(id)
+		require.Len(t, alias, len(prev))
+
+		//more than the last character changes
+		assert.NotEqual(t, prev[:len(prev)-1], alias[:len(alias)-1], "%d: %s, %s", id, prev, alias)
+		ordered = ordered && prev < alias
+		prev = alias
+	}
+	assert.False(t, ordered, "aliases follow the order of the ids")
+}
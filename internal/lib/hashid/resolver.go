@@ -0,0 +1,52 @@
+package hashid
+
+import (
+	"context"
+	"errors"
+	"time"
+	"url-shortener/internal/storage"
+)
+
+type URLGetter interface {
+	GetURLByID(ctx context.Context, id int64) (storage.URL, error)
+	GetURLByAlias(ctx context.Context, alias string) (storage.URL, error)
+}
+
+// Resolver resolves aliases by primary key when they decode to an id,
+// and falls back to the alias index for custom aliases.
+type Resolver struct {
+	codec  *Codec
+	getter URLGetter
+}
+
+func NewResolver(codec *Codec, getter URLGetter) *Resolver {
+	return &Resolver{codec: codec, getter: getter}
+}
+
+func (r *Resolver) GetURL(ctx context.Context, alias string) (string, error) {
+	u, err := r.GetURLByAlias(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+	if u.ExpiresAt != nil && !time.Now().Before(*u.ExpiresAt) {
+		return "", storage.ErrURLExpired
+	}
+
+	return u.URL, nil
+}
+
+// GetURLByAlias returns the url row with alias, expired or not.
+func (r *Resolver) GetURLByAlias(ctx context.Context, alias string) (storage.URL, error) {
+	if id, ok := r.codec.Decode(alias); ok {
+		u, err := r.getter.GetURLByID(ctx, id)
+		switch {
+		// a custom alias may decode to the id of an unrelated row
+		case err == nil && u.Alias == alias:
+			return u, nil
+		case err != nil && !errors.Is(err, storage.ErrUrlNotFound):
+			return storage.URL{}, err
+		}
+	}
+
+	return r.getter.GetURLByAlias(ctx, alias)
+}
@@ -0,0 +1,205 @@
+// Package linkfile reads and writes urls as CSV, a JSON array or NDJSON,
+// for the import and export commands.
+package linkfile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"url-shortener/internal/storage"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// columns of the CSV format; import needs alias and url, the rest is optional
+var columns = []string{"alias", "url", "owner", "created_at", "expires_at"}
+
+// FormatFromPath guesses the format from the file extension, defaulting to csv.
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	}
+	return FormatCSV
+}
+
+// Writer writes urls one by one. Close must be called to complete the output.
+type Writer struct {
+	format string
+	w      io.Writer
+	csv    *csv.Writer
+	enc    *json.Encoder
+	n      int
+}
+
+func NewWriter(w io.Writer, format string) (*Writer, error) {
+	lw := &Writer{format: format, w: w}
+
+	switch format {
+	case FormatCSV:
+		lw.csv = csv.NewWriter(w)
+		if err := lw.csv.Write(columns); err != nil {
+			return nil, err
+		}
+	case FormatJSON:
+		if _, err := io.WriteString(w, "["); err != nil {
+			return nil, err
+		}
+		lw.enc = json.NewEncoder(w)
+	case FormatNDJSON:
+		lw.enc = json.NewEncoder(w)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+
+	return lw, nil
+}
+
+func (lw *Writer) Write(u storage.URL) error {
+	defer func() { lw.n++ }()
+
+	switch lw.format {
+	case FormatCSV:
+		expiresAt := ""
+		if u.ExpiresAt != nil {
+			expiresAt = u.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		return lw.csv.Write([]string{u.Alias, u.URL, u.Owner, u.CreatedAt.UTC().Format(time.RFC3339), expiresAt})
+	case FormatJSON:
+		if lw.n > 0 {
+			if _, err := io.WriteString(lw.w, ","); err != nil {
+				return err
+			}
+		}
+	}
+
+	return lw.enc.Encode(u)
+}
+
+func (lw *Writer) Close() error {
+	switch lw.format {
+	case FormatCSV:
+		lw.csv.Flush()
+		return lw.csv.Error()
+	case FormatJSON:
+		_, err := io.WriteString(lw.w, "]\n")
+		return err
+	}
+
+	return nil
+}
+
+// Read parses all urls of r. Errors name the record they were found in.
+func Read(r io.Reader, format string) ([]storage.URL, error) {
+	var urls []storage.URL
+	var err error
+
+	switch format {
+	case FormatCSV:
+		urls, err = readCSV(r)
+	case FormatJSON:
+		err = json.NewDecoder(r).Decode(&urls)
+	case FormatNDJSON:
+		dec := json.NewDecoder(r)
+		for {
+			var u storage.URL
+			if err = dec.Decode(&u); err != nil {
+				break
+			}
+			urls = append(urls, u)
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		} else if err != nil {
+			err = fmt.Errorf("record %d: %w", len(urls)+1, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	for i, u := range urls {
+		if u.Alias == "" {
+			return nil, fmt.Errorf("record %d: alias is empty", i+1)
+		}
+		if err := validate.Var(u.URL, "required,url"); err != nil {
+			return nil, fmt.Errorf("record %d: invalid url %q", i+1, u.URL)
+		}
+	}
+
+	return urls, nil
+}
+
+func readCSV(r io.Reader) ([]storage.URL, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(columns, name) {
+			return nil, fmt.Errorf("header: unknown column %q", name)
+		}
+		index[name] = i
+	}
+	for _, name := range columns[:2] {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("header: missing column %q", name)
+		}
+	}
+
+	var urls []storage.URL
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return urls, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		u := storage.URL{Alias: field("alias"), URL: field("url"), Owner: field("owner")}
+		if v := field("created_at"); v != "" {
+			if u.CreatedAt, err = time.Parse(time.RFC3339, v); err != nil {
+				return nil, fmt.Errorf("line %d: invalid created_at %q", line, v)
+			}
+		}
+		if v := field("expires_at"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid expires_at %q", line, v)
+			}
+			u.ExpiresAt = &t
+		}
+		urls = append(urls, u)
+	}
+}
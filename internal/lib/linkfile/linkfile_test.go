@@ -0,0 +1,67 @@
+package linkfile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	urls := []storage.URL{
+		{Alias: "a", URL: "https://example.com/a?x=1,2", Owner: "alice", CreatedAt: time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)},
+		{Alias: "b", URL: "https://example.com/b", Owner: "", CreatedAt: time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC), ExpiresAt: &expiresAt},
+	}
+
+	for _, format := range []string{FormatCSV, FormatJSON, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, format)
+			require.NoError(t, err)
+			for _, u := range urls {
+				require.NoError(t, w.Write(u))
+			}
+			require.NoError(t, w.Close())
+
+			got, err := Read(&buf, format)
+			require.NoError(t, err)
+			assert.Equal(t, urls, got)
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	got, err := Read(strings.NewReader("url,alias\nhttps://example.com,ex\n"), FormatCSV)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.URL{{Alias: "ex", URL: "https://example.com"}}, got)
+
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{name: "missing column", input: "alias\nex\n", err: `header: missing column "url"`},
+		{name: "unknown column", input: "alias,url,clicks\n", err: `header: unknown column "clicks"`},
+		{name: "invalid url", input: "alias,url\nex,not a url\n", err: `record 1: invalid url "not a url"`},
+		{name: "empty alias", input: "alias,url\n,https://example.com\n", err: "record 1: alias is empty"},
+		{name: "invalid time", input: "alias,url,created_at\nex,https://example.com,yesterday\n", err: `line 2: invalid created_at "yesterday"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.input), FormatCSV)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	assert.Equal(t, FormatCSV, FormatFromPath("links.csv"))
+	assert.Equal(t, FormatJSON, FormatFromPath("backup.JSON"))
+	assert.Equal(t, FormatNDJSON, FormatFromPath("links.jsonl"))
+	assert.Equal(t, FormatCSV, FormatFromPath("links"))
+}
@@ -0,0 +1,89 @@
+// Package metrics holds the Prometheus metrics of the service, served by Handler.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "url_shortener"
+
+// Results of a redirect lookup.
+const (
+	RedirectHit     = "hit"
+	RedirectMiss    = "miss"
+	RedirectExpired = "expired"
+	RedirectError   = "error"
+)
+
+// Results of an alias cache lookup.
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// Results of a storage operation.
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// registry is separate from prometheus.DefaultRegisterer, so that libraries
+// cannot add metrics behind our back.
+var registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestDuration = promauto.With(registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route pattern, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	Redirects = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_total",
+		Help:      "Alias lookups of the redirect handler by result: hit, miss, expired or error.",
+	}, []string{"result"})
+
+	AliasRetries = promauto.With(registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alias_retries_total",
+		Help:      "Aliases generated again because the previous one was taken.",
+	})
+
+	CacheLookups = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Alias lookups of the redirect cache by tier and result: hit or miss.",
+	}, []string{"tier", "result"})
+
+	StorageDuration = promauto.With(registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Latency of storage operations by op and result.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"op", "result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
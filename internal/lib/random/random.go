@@ -1,18 +1,112 @@
 package random
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
 )
 
-func GenerateRandomString(length int) string {
-	stroke := ""
+const (
+	StrategyBase62 = "base62"
+	StrategyHuman  = "human"
+	StrategyWords  = "words"
+)
 
-	words := "abcdefghijklmnopqrstuvwxyz"
+const (
+	AlphabetLower  = "abcdefghijklmnopqrstuvwxyz"
+	AlphabetBase62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// AlphabetHuman leaves out characters that are easy to confuse when read aloud
+	// or retyped from print: 0/o, 1/l/i, and upper case altogether.
+	AlphabetHuman = "23456789abcdefghjkmnpqrstuvwxyz"
+)
 
-	for i := 0; i < length; i++ {
-		stroke += string(words[rand.Intn(len(words))])
+// Generator produces random aliases.
+type Generator interface {
+	Generate() string
+}
+
+// New returns the generator of strategy. alphabet overrides the default alphabet
+// of the base62 and human strategies; length is ignored by the words strategy.
+func New(strategy string, length int, alphabet string) (Generator, error) {
+	const op = "lib.random.New"
+
+	switch strategy {
+	case StrategyBase62, StrategyHuman:
+		if alphabet == "" {
+			alphabet = AlphabetBase62
+			if strategy == StrategyHuman {
+				alphabet = AlphabetHuman
+			}
+		}
+		g, err := NewAlphabet(alphabet, length)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return g, nil
+	case StrategyWords:
+		return Words{}, nil
 	}
-	return stroke
+
+	return nil, fmt.Errorf("%s: unknown alias strategy %q", op, strategy)
 }
 
-//TODO: add check in bd for existing strings and regenerate if exists
\ No newline at end of file
+// Alphabet draws length characters uniformly from a fixed alphabet using crypto/rand.
+type Alphabet struct {
+	alphabet string
+	length   int
+}
+
+func NewAlphabet(alphabet string, length int) (*Alphabet, error) {
+	if length < 1 {
+		return nil, errors.New("alias length must be positive")
+	}
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		return nil, errors.New("alphabet must have between 2 and 256 characters")
+	}
+	for i := 0; i < len(alphabet); i++ {
+		if alphabet[i] >= 0x80 {
+			return nil, errors.New("alphabet must be ASCII")
+		}
+		if strings.IndexByte(alphabet[i+1:], alphabet[i]) >= 0 {
+			return nil, fmt.Errorf("alphabet has duplicate character %q", alphabet[i])
+		}
+	}
+
+	return &Alphabet{alphabet: alphabet, length: length}, nil
+}
+
+func (a *Alphabet) Generate() string {
+	return randomString(a.alphabet, a.length)
+}
+
+// GenerateRandomString returns a crypto-random string of lowercase letters.
+func GenerateRandomString(length int) string {
+	return randomString(AlphabetLower, length)
+}
+
+// randomString uses rejection sampling, so every character of alphabet is equally likely.
+func randomString(alphabet string, length int) string {
+	n := len(alphabet)
+	// largest multiple of n that fits in a byte; bytes above it would bias the result
+	limit := 256 - 256%n
+
+	var b strings.Builder
+	b.Grow(length)
+
+	buf := make([]byte, length+length/2)
+	for b.Len() < length {
+		_, _ = rand.Read(buf) // never fails since Go 1.24
+		for _, c := range buf {
+			if int(c) >= limit {
+				continue
+			}
+			b.WriteByte(alphabet[int(c)%n])
+			if b.Len() == length {
+				break
+			}
+		}
+	}
+
+	return b.String()
+}
@@ -1,6 +1,7 @@
 package random
 
 import (
+	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -43,4 +44,86 @@ func TestNewRandomString(t *testing.T) {
 			assert.NotEqual(t, str1, str2)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		length   int
+		alphabet string
+		match    *regexp.Regexp
+		wantErr  bool
+	}{
+		{
+			name:     "base62",
+			strategy: StrategyBase62,
+			length:   8,
+			match:    regexp.MustCompile(`^[0-9A-Za-z]{8}$`),
+		},
+		{
+			name:     "human",
+			strategy: StrategyHuman,
+			length:   10,
+			match:    regexp.MustCompile(`^[2-9a-hjkmnp-z]{10}$`),
+		},
+		{
+			name:     "custom alphabet",
+			strategy: StrategyBase62,
+			length:   12,
+			alphabet: "ab",
+			match:    regexp.MustCompile(`^[ab]{12}$`),
+		},
+		{
+			name:     "words",
+			strategy: StrategyWords,
+			match:    regexp.MustCompile(`^[a-z]+-[a-z]+-\d{1,3}$`),
+		},
+		{
+			name:     "unknown strategy",
+			strategy: "uuid",
+			wantErr:  true,
+		},
+		{
+			name:     "zero length",
+			strategy: StrategyBase62,
+			length:   0,
+			wantErr:  true,
+		},
+		{
+			name:     "duplicate characters",
+			strategy: StrategyHuman,
+			length:   8,
+			alphabet: "abca",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(tt.strategy, tt.length, tt.alphabet)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			for i := 0; i < 100; i++ {
+				assert.Regexp(t, tt.match, g.Generate())
+			}
+		})
+	}
+}
+
+func TestAlphabet_Uniform(t *testing.T) {
+	g, err := NewAlphabet("abc", 30000)
+	assert.NoError(t, err)
+
+	counts := map[rune]int{}
+	for _, c := range g.Generate() {
+		counts[c]++
+	}
+
+	for _, c := range "abc" {
+		assert.InDelta(t, 10000, counts[c], 600)
+	}
+}
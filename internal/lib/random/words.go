@@ -0,0 +1,62 @@
+package random
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// Words generates aliases like "brave-otter-42": easy to say and remember,
+// at the cost of fewer combinations (about 16 million) than character strategies.
+type Words struct{}
+
+func (Words) Generate() string {
+	return fmt.Sprintf("%s-%s-%d", pick(adjectives), pick(nouns), randInt(1000))
+}
+
+func pick(words []string) string {
+	return words[randInt(len(words))]
+}
+
+func randInt(n int) int {
+	v, _ := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	return int(v.Int64())
+}
+
+var adjectives = []string{
+	"able", "acid", "agile", "alert", "amber", "ample", "arctic", "azure",
+	"bold", "brave", "brief", "bright", "brisk", "calm", "candid", "clean",
+	"clear", "clever", "cool", "cosmic", "cozy", "crisp", "curly", "daring",
+	"deep", "dense", "eager", "early", "easy", "elder", "epic", "even",
+	"exact", "fair", "fancy", "fast", "fine", "firm", "first", "fluffy",
+	"fond", "free", "fresh", "frosty", "gentle", "giant", "glad", "golden",
+	"grand", "green", "happy", "hardy", "hasty", "honest", "humble", "icy",
+	"ideal", "jolly", "keen", "kind", "lively", "lucky", "lunar", "magic",
+	"merry", "mighty", "mild", "misty", "modern", "noble", "quick", "quiet",
+	"rapid", "rare", "ready", "regal", "rich", "robust", "rosy", "royal",
+	"rustic", "safe", "salty", "sandy", "sharp", "shiny", "silent", "silky",
+	"simple", "sleek", "smart", "smooth", "snowy", "solar", "solid", "sonic",
+	"spicy", "steady", "still", "stormy", "strong", "sturdy", "sunny", "super",
+	"sweet", "swift", "tall", "tidy", "tiny", "topaz", "tough", "tranquil",
+	"true", "upbeat", "urban", "vast", "velvet", "vivid", "warm", "wavy",
+	"wild", "wise", "witty", "young", "zany", "zealous", "zesty", "zippy",
+}
+
+var nouns = []string{
+	"acorn", "anchor", "apple", "arrow", "badger", "bamboo", "beacon", "bear",
+	"beaver", "bison", "breeze", "brook", "cactus", "canyon", "cedar", "cheetah",
+	"cliff", "cloud", "comet", "coral", "cougar", "crane", "creek", "crow",
+	"delta", "desert", "dolphin", "dove", "dragon", "eagle", "ember", "falcon",
+	"fern", "finch", "fjord", "flame", "forest", "fox", "galaxy", "gecko",
+	"glacier", "grove", "harbor", "hawk", "heron", "hill", "island", "jaguar",
+	"jasper", "koala", "lagoon", "lake", "lantern", "lark", "lemur", "lion",
+	"lotus", "lynx", "maple", "meadow", "meteor", "mole", "moose", "moth",
+	"nebula", "newt", "oak", "ocean", "orbit", "orca", "osprey", "otter",
+	"owl", "panda", "panther", "parrot", "pebble", "pelican", "penguin", "pine",
+	"planet", "plume", "pond", "puffin", "quail", "quartz", "rabbit", "raven",
+	"reef", "river", "robin", "rocket", "salmon", "sequoia", "shark", "sparrow",
+	"spruce", "star", "stone", "summit", "swan", "thunder", "tiger", "toucan",
+	"trout", "tulip", "tundra", "turtle", "valley", "violet", "volcano", "walrus",
+	"willow", "wolf", "wombat", "wren", "yak", "zebra", "aurora", "bay",
+	"birch", "blossom", "boulder", "harp", "iris", "kestrel", "marlin", "mesa",
+}
@@ -0,0 +1,39 @@
+package reaper
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	my_slog "url-shortener/internal/lib/logger/my_slog"
+)
+
+type ExpiredDeleter interface {
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Run purges expired urls every interval until ctx is cancelled.
+func Run(ctx context.Context, log *slog.Logger, deleter ExpiredDeleter, interval time.Duration) {
+	const op = "lib.reaper.Run"
+
+	log = log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			deleted, err := deleter.DeleteExpired(ctx, now)
+			if err != nil {
+				log.Error("failed to delete expired urls", my_slog.Err(err))
+				continue
+			}
+			if deleted > 0 {
+				log.Info("deleted expired urls", slog.Int64("count", deleted))
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Package cache keeps recently resolved aliases in memory, so that redirects of
+// hot links do not query the storage every time.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/storage"
+)
+
+// Tiers in metrics.CacheLookups.
+const (
+	tierMemory = "memory"
+	tierRedis  = "redis"
+)
+
+// URLGetter resolves the aliases missing from the cache.
+type URLGetter interface {
+	GetURLByAlias(ctx context.Context, alias string) (storage.URL, error)
+}
+
+type Options struct {
+	Size        int           //entries kept at most, the least recently used are evicted
+	TTL         time.Duration //how long a resolved alias is served from the cache
+	NegativeTTL time.Duration //how long an alias stays known as unknown; 0 disables negative caching
+}
+
+// Stats are the counters of the cache since it was created.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+// Cache is a bounded LRU cache of alias resolutions. Entries go stale after their
+// TTL, and links that expire while cached are reported as storage.ErrURLExpired.
+type Cache struct {
+	getter URLGetter
+	opts   Options
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List //of *entry, most recently used first
+	//bumped by every invalidation, so that lookups started before it are not cached
+	gen   uint64
+	stats Stats
+}
+
+type entry struct {
+	alias     string
+	url       string
+	expiresAt time.Time //of the link, zero if it never expires
+	err       error     //storage.ErrUrlNotFound for unknown aliases
+	staleAt   time.Time
+}
+
+func New(getter URLGetter, opts Options) *Cache {
+	return &Cache{
+		getter:  getter,
+		opts:    opts,
+		now:     time.Now,
+		entries: make(map[string]*list.Element, opts.Size),
+		lru:     list.New(),
+	}
+}
+
+// GetURL resolves alias like storage.URLStore.GetURL, from the cache when possible.
+func (c *Cache) GetURL(ctx context.Context, alias string) (string, error) {
+	now := c.now()
+
+	e, gen, ok := c.get(alias, now)
+	if ok {
+		metrics.CacheLookups.WithLabelValues(tierMemory, metrics.CacheHit).Inc()
+		return e.result(now)
+	}
+	metrics.CacheLookups.WithLabelValues(tierMemory, metrics.CacheMiss).Inc()
+
+	u, err := c.getter.GetURLByAlias(ctx, alias)
+	switch {
+	case err == nil:
+		e = &entry{alias: alias, url: u.URL, staleAt: now.Add(c.opts.TTL)}
+		if u.ExpiresAt != nil {
+			e.expiresAt = *u.ExpiresAt
+		}
+		c.add(e, gen)
+	case errors.Is(err, storage.ErrUrlNotFound):
+		e = &entry{alias: alias, err: storage.ErrUrlNotFound, staleAt: now.Add(c.opts.NegativeTTL)}
+		if c.opts.NegativeTTL > 0 {
+			c.add(e, gen)
+		}
+	default:
+		return "", err
+	}
+
+	return e.result(now)
+}
+
+// Invalidate drops aliases from the cache. It must be called after every write
+// to them, including saves, since unknown aliases are cached too.
+func (c *Cache) Invalidate(_ context.Context, aliases ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, alias := range aliases {
+		if el, ok := c.entries[alias]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+// get returns the fresh entry of alias, or the generation to add it with.
+func (c *Cache) get(alias string, now time.Time) (*entry, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[alias]
+	if ok {
+		e := el.Value.(*entry)
+		if now.Before(e.staleAt) {
+			c.lru.MoveToFront(el)
+			c.stats.Hits++
+			return e, 0, true
+		}
+		c.remove(el)
+	}
+
+	c.stats.Misses++
+	return nil, c.gen, false
+}
+
+// add caches e unless the cache was invalidated since generation gen.
+func (c *Cache) add(e *entry, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen || c.opts.Size <= 0 {
+		return
+	}
+
+	if el, ok := c.entries[e.alias]; ok {
+		c.remove(el)
+	}
+	c.entries[e.alias] = c.lru.PushFront(e)
+
+	for c.lru.Len() > c.opts.Size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*entry).alias)
+}
+
+func (e *entry) result(now time.Time) (string, error) {
+	if e.err != nil {
+		return "", e.err
+	}
+	if !e.expiresAt.IsZero() && !now.Before(e.expiresAt) {
+		return "", storage.ErrURLExpired
+	}
+
+	return e.url, nil
+}
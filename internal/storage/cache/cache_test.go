@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeGetter struct {
+	mu    sync.Mutex
+	urls  map[string]storage.URL
+	calls int
+}
+
+func (f *fakeGetter) GetURLByAlias(_ context.Context, alias string) (storage.URL, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	u, ok := f.urls[alias]
+	if !ok {
+		return storage.URL{}, storage.ErrUrlNotFound
+	}
+	return u, nil
+}
+
+func (f *fakeGetter) set(alias string, u storage.URL) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.urls[alias] = u
+}
+
+func newCache(opts Options) (*Cache, *fakeGetter, *time.Time) {
+	getter := &fakeGetter{urls: map[string]storage.URL{}}
+	c := New(getter, opts)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	return c, getter, &now
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("hits until stale", func(t *testing.T) {
+		c, getter, now := newCache(Options{Size: 10, TTL: time.Minute})
+		getter.set("a", storage.URL{URL: "https://example.com/a"})
+
+		for range 3 {
+			got, err := c.GetURL(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, "https://example.com/a", got)
+		}
+		assert.Equal(t, 1, getter.calls)
+
+		*now = now.Add(time.Minute)
+		_, err := c.GetURL(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, 2, getter.calls)
+
+		assert.Equal(t, Stats{Hits: 2, Misses: 2, Entries: 1}, c.Stats())
+	})
+
+	t.Run("link expires while cached", func(t *testing.T) {
+		c, getter, now := newCache(Options{Size: 10, TTL: time.Hour})
+		expiresAt := now.Add(time.Minute)
+		getter.set("a", storage.URL{URL: "https://example.com/a", ExpiresAt: &expiresAt})
+
+		_, err := c.GetURL(ctx, "a")
+		require.NoError(t, err)
+
+		*now = expiresAt
+		_, err = c.GetURL(ctx, "a")
+		assert.ErrorIs(t, err, storage.ErrURLExpired)
+		assert.Equal(t, 1, getter.calls)
+	})
+
+	t.Run("negative caching", func(t *testing.T) {
+		c, getter, now := newCache(Options{Size: 10, TTL: time.Hour, NegativeTTL: time.Second})
+
+		for range 2 {
+			_, err := c.GetURL(ctx, "a")
+			assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+		}
+		assert.Equal(t, 1, getter.calls)
+
+		*now = now.Add(time.Second)
+		_, err := c.GetURL(ctx, "a")
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+		assert.Equal(t, 2, getter.calls)
+
+		getter.set("a", storage.URL{URL: "https://example.com/a"})
+		c.Invalidate(ctx, "a")
+		got, err := c.GetURL(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/a", got)
+	})
+
+	t.Run("no negative caching", func(t *testing.T) {
+		c, getter, _ := newCache(Options{Size: 10, TTL: time.Hour})
+
+		for range 2 {
+			_, err := c.GetURL(ctx, "a")
+			assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+		}
+		assert.Equal(t, 2, getter.calls)
+	})
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		c, getter, _ := newCache(Options{Size: 2, TTL: time.Hour})
+		for _, alias := range []string{"a", "b", "c"} {
+			getter.set(alias, storage.URL{URL: "https://example.com/" + alias})
+		}
+
+		for _, alias := range []string{"a", "b", "a", "c"} {
+			_, err := c.GetURL(ctx, alias)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 3, getter.calls)
+
+		//b was evicted for c, a is still cached
+		for _, alias := range []string{"a", "b"} {
+			_, err := c.GetURL(ctx, alias)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 4, getter.calls)
+		assert.Equal(t, uint64(2), c.Stats().Evictions)
+		assert.Equal(t, 2, c.Stats().Entries)
+	})
+
+	t.Run("lookup older than invalidation is not cached", func(t *testing.T) {
+		c, getter, _ := newCache(Options{Size: 10, TTL: time.Hour})
+		getter.set("a", storage.URL{URL: "https://example.com/old"})
+
+		_, gen, ok := c.get("a", c.now())
+		require.False(t, ok)
+		c.Invalidate(ctx, "a")
+		c.add(&entry{alias: "a", url: "https://example.com/old", staleAt: c.now().Add(time.Hour)}, gen)
+
+		getter.set("a", storage.URL{URL: "https://example.com/new"})
+		got, err := c.GetURL(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/new", got)
+	})
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sqlite.New(filepath.Join(t.TempDir(), "storage.db"), sqlite.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	_, err = db.Migrator().Up(context.Background())
+	require.NoError(t, err)
+
+	c := New(db, Options{Size: 10, TTL: time.Hour, NegativeTTL: time.Hour})
+	store := NewStore(db, c)
+
+	_, err = c.GetURL(ctx, "a")
+	assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+
+	_, err = store.SaveURLIfAbsent(ctx, "https://example.com/a", "a", "owner", time.Time{})
+	require.NoError(t, err)
+	got, err := c.GetURL(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/a", got)
+
+	require.NoError(t, store.UpdateURL(ctx, "a", "https://example.com/b", ""))
+	got, err = c.GetURL(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/b", got)
+
+	require.NoError(t, store.DeleteURL(ctx, "a", ""))
+	_, err = c.GetURL(ctx, "a")
+	assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+
+	_, err = store.SaveURLs(ctx, []storage.NewURL{{URL: "https://example.com/c", Alias: "a"}}, nil)
+	require.NoError(t, err)
+	got, err = c.GetURL(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/c", got)
+
+	errs, err := store.DeleteURLs(ctx, []string{"a"}, "")
+	require.NoError(t, err)
+	require.NoError(t, errs[0])
+	_, err = c.GetURL(ctx, "a")
+	assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+	my_slog "url-shortener/internal/lib/logger/my_slog"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/storage"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fillWindow is how long an invalidated alias is not cached again in Redis, so
+// that lookups which read the old url before the write cannot store it afterwards.
+const fillWindow = 5 * time.Second
+
+// tombstone is the value of invalidated aliases during the fill window.
+const tombstone = "-"
+
+type RedisOptions struct {
+	KeyPrefix   string        //of the keys of aliases and of the invalidation channel
+	TTL         time.Duration //how long a resolved alias is kept
+	NegativeTTL time.Duration //how long an alias stays known as unknown; 0 disables negative caching
+}
+
+// Redis is a cache tier in a Redis-compatible server, shared by all replicas.
+// It sits between their in-process caches and the storage: invalidated aliases
+// are deleted from the server and published to the other replicas (see Subscribe).
+// When the server fails, aliases are resolved by the storage.
+type Redis struct {
+	log    *slog.Logger
+	client *redis.Client
+	getter URLGetter
+	opts   RedisOptions
+	now    func() time.Time
+}
+
+// redisEntry is the cached resolution of an alias.
+type redisEntry struct {
+	URL       string     `json:"url,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	NotFound  bool       `json:"not_found,omitempty"`
+}
+
+func NewRedis(log *slog.Logger, client *redis.Client, getter URLGetter, opts RedisOptions) *Redis {
+	return &Redis{
+		log:    log.With(slog.String("component", "cache/redis")),
+		client: client,
+		getter: getter,
+		opts:   opts,
+		now:    time.Now,
+	}
+}
+
+// GetURL resolves alias like storage.URLStore.GetURL, from the cache when possible.
+func (r *Redis) GetURL(ctx context.Context, alias string) (string, error) {
+	u, err := r.GetURLByAlias(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+	if u.ExpiresAt != nil && !r.now().Before(*u.ExpiresAt) {
+		return "", storage.ErrURLExpired
+	}
+
+	return u.URL, nil
+}
+
+// GetURLByAlias returns the url and expiration of alias, expired or not.
+// Other fields are set only when the alias was resolved by the storage.
+func (r *Redis) GetURLByAlias(ctx context.Context, alias string) (storage.URL, error) {
+	if e, ok := r.get(ctx, alias); ok {
+		metrics.CacheLookups.WithLabelValues(tierRedis, metrics.CacheHit).Inc()
+		if e.NotFound {
+			return storage.URL{}, storage.ErrUrlNotFound
+		}
+		return storage.URL{Alias: alias, URL: e.URL, ExpiresAt: e.ExpiresAt}, nil
+	}
+	metrics.CacheLookups.WithLabelValues(tierRedis, metrics.CacheMiss).Inc()
+
+	u, err := r.getter.GetURLByAlias(ctx, alias)
+	switch {
+	case err == nil:
+		r.fill(ctx, alias, redisEntry{URL: u.URL, ExpiresAt: u.ExpiresAt}, r.opts.TTL)
+	case errors.Is(err, storage.ErrUrlNotFound) && r.opts.NegativeTTL > 0:
+		r.fill(ctx, alias, redisEntry{NotFound: true}, r.opts.NegativeTTL)
+	}
+
+	return u, err
+}
+
+// Invalidate deletes aliases from the server and publishes them to the replicas.
+// Failures are logged: the aliases stay cached until their TTL.
+func (r *Redis) Invalidate(ctx context.Context, aliases ...string) {
+	if len(aliases) == 0 {
+		return
+	}
+
+	msg, err := json.Marshal(aliases)
+	if err != nil {
+		r.log.Error("failed to encode invalidation", my_slog.Err(err))
+		return
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, alias := range aliases {
+			pipe.Set(ctx, r.key(alias), tombstone, fillWindow)
+		}
+		pipe.Publish(ctx, r.channel(), msg)
+		return nil
+	})
+	if err != nil {
+		r.log.Error("failed to invalidate aliases", slog.Int("aliases", len(aliases)), my_slog.Err(err))
+	}
+}
+
+// Subscribe invalidates the aliases published by any replica in tier until ctx
+// is done. Invalidations published while the connection is down are missed, so
+// the entries of tier go stale after their own TTL only.
+func (r *Redis) Subscribe(ctx context.Context, tier Invalidator) {
+	sub := r.client.Subscribe(ctx, r.channel())
+	defer func() { _ = sub.Close() }()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var aliases []string
+			if err := json.Unmarshal([]byte(msg.Payload), &aliases); err != nil {
+				r.log.Error("invalid invalidation message", my_slog.Err(err))
+				continue
+			}
+			tier.Invalidate(ctx, aliases...)
+		}
+	}
+}
+
+func (r *Redis) get(ctx context.Context, alias string) (redisEntry, bool) {
+	val, err := r.client.Get(ctx, r.key(alias)).Result()
+	if errors.Is(err, redis.Nil) || val == tombstone {
+		return redisEntry{}, false
+	}
+	if err != nil {
+		r.log.Warn("failed to read alias, falling back to storage", slog.String("alias", alias), my_slog.Err(err))
+		return redisEntry{}, false
+	}
+
+	var e redisEntry
+	if err := json.Unmarshal([]byte(val), &e); err != nil {
+		r.log.Error("invalid cached alias", slog.String("alias", alias), my_slog.Err(err))
+		return redisEntry{}, false
+	}
+	return e, true
+}
+
+// fill caches e unless alias was invalidated within the fill window.
+func (r *Redis) fill(ctx context.Context, alias string, e redisEntry, ttl time.Duration) {
+	val, err := json.Marshal(e)
+	if err != nil {
+		r.log.Error("failed to encode alias", slog.String("alias", alias), my_slog.Err(err))
+		return
+	}
+
+	err = r.client.SetArgs(ctx, r.key(alias), val, redis.SetArgs{Mode: "NX", TTL: ttl}).Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		r.log.Warn("failed to cache alias", slog.String("alias", alias), my_slog.Err(err))
+	}
+}
+
+func (r *Redis) key(alias string) string {
+	return r.opts.KeyPrefix + "alias:" + alias
+}
+
+func (r *Redis) channel() string {
+	return r.opts.KeyPrefix + "invalidate"
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+	"url-shortener/internal/storage"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var redisOptions = RedisOptions{KeyPrefix: "test:", TTL: time.Hour, NegativeTTL: time.Minute}
+
+// newReplica returns the redis tier of a replica that shares getter and the server.
+func newReplica(t *testing.T, server *miniredis.Miniredis, getter URLGetter) *Redis {
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { _ = client.Close() })
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	return NewRedis(log, client, getter, redisOptions)
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("replicas share resolved aliases", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		getter := &fakeGetter{urls: map[string]storage.URL{}}
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		getter.set("a", storage.URL{URL: "https://example.com/a", ExpiresAt: &expiresAt})
+		a, b := newReplica(t, server, getter), newReplica(t, server, getter)
+
+		got, err := a.GetURL(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/a", got)
+
+		u, err := b.GetURLByAlias(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/a", u.URL)
+		require.NotNil(t, u.ExpiresAt)
+		assert.True(t, expiresAt.Equal(*u.ExpiresAt))
+		assert.Equal(t, 1, getter.calls)
+		assert.Equal(t, time.Hour, server.TTL("test:alias:a"))
+	})
+
+	t.Run("negative caching", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		getter := &fakeGetter{urls: map[string]storage.URL{}}
+		a, b := newReplica(t, server, getter), newReplica(t, server, getter)
+
+		_, err := a.GetURL(ctx, "a")
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+		_, err = b.GetURL(ctx, "a")
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+		assert.Equal(t, 1, getter.calls)
+
+		server.FastForward(time.Minute)
+		_, err = b.GetURL(ctx, "a")
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+		assert.Equal(t, 2, getter.calls)
+	})
+
+	t.Run("invalidated aliases are not filled within the window", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		getter := &fakeGetter{urls: map[string]storage.URL{}}
+		getter.set("a", storage.URL{URL: "https://example.com/old"})
+		a := newReplica(t, server, getter)
+
+		_, err := a.GetURL(ctx, "a")
+		require.NoError(t, err)
+
+		getter.set("a", storage.URL{URL: "https://example.com/new"})
+		a.Invalidate(ctx, "a")
+		for range 2 {
+			got, err := a.GetURL(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, "https://example.com/new", got)
+		}
+		assert.Equal(t, 3, getter.calls)
+
+		server.FastForward(fillWindow)
+		for range 2 {
+			_, err = a.GetURL(ctx, "a")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 4, getter.calls)
+	})
+
+	t.Run("invalidations reach the memory caches of all replicas", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		getter := &fakeGetter{urls: map[string]storage.URL{}}
+		getter.set("a", storage.URL{URL: "https://example.com/old"})
+		a, b := newReplica(t, server, getter), newReplica(t, server, getter)
+		memory := New(b, Options{Size: 10, TTL: time.Hour})
+
+		subCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			b.Subscribe(subCtx, memory)
+		}()
+		t.Cleanup(func() {
+			cancel()
+			<-done
+		})
+		require.Eventually(t, func() bool {
+			return len(server.PubSubChannels("")) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		got, err := memory.GetURL(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/old", got)
+
+		getter.set("a", storage.URL{URL: "https://example.com/new"})
+		a.Invalidate(ctx, "a")
+
+		assert.Eventually(t, func() bool {
+			got, err := memory.GetURL(ctx, "a")
+			return err == nil && got == "https://example.com/new"
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("falls back to storage when the server is down", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		getter := &fakeGetter{urls: map[string]storage.URL{}}
+		getter.set("a", storage.URL{URL: "https://example.com/a"})
+		a := newReplica(t, server, getter)
+		server.Close()
+
+		got, err := a.GetURL(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/a", got)
+
+		a.Invalidate(ctx, "a")
+	})
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"time"
+	"url-shortener/internal/storage"
+)
+
+// Invalidator is a cache tier that drops aliases once they are written.
+type Invalidator interface {
+	Invalidate(ctx context.Context, aliases ...string)
+}
+
+// Store invalidates the aliases written through it in every cache tier, so that
+// redirects see saves, updates and deletes at once. Tiers are invalidated in order,
+// so shared tiers must come before the ones they fill: otherwise a lookup between
+// the two invalidations could cache the old url again.
+type Store struct {
+	storage.Store
+	tiers []Invalidator
+}
+
+func NewStore(store storage.Store, tiers ...Invalidator) *Store {
+	return &Store{Store: store, tiers: tiers}
+}
+
+func (s *Store) SaveURL(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (int64, error) {
+	defer s.invalidate(ctx, alias)
+	return s.Store.SaveURL(ctx, urlToSave, alias, owner, expiresAt)
+}
+
+func (s *Store) SaveURLIfAbsent(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (storage.Saved, error) {
+	defer s.invalidate(ctx, alias)
+	return s.Store.SaveURLIfAbsent(ctx, urlToSave, alias, owner, expiresAt)
+}
+
+func (s *Store) SaveURLWithIDAlias(ctx context.Context, urlToSave string, owner string, expiresAt time.Time, encode func(id int64) string) (storage.Saved, error) {
+	saved, err := s.Store.SaveURLWithIDAlias(ctx, urlToSave, owner, expiresAt, encode)
+	s.invalidate(ctx, saved.Alias)
+	return saved, err
+}
+
+func (s *Store) SaveURLs(ctx context.Context, urls []storage.NewURL, encode func(id int64) string) ([]storage.SaveResult, error) {
+	results, err := s.Store.SaveURLs(ctx, urls, encode)
+
+	aliases := make([]string, 0, len(urls)+len(results))
+	for _, u := range urls {
+		aliases = append(aliases, u.Alias)
+	}
+	for _, res := range results {
+		aliases = append(aliases, res.Alias)
+	}
+	s.invalidate(ctx, aliases...)
+
+	return results, err
+}
+
+func (s *Store) UpdateURL(ctx context.Context, alias string, newURL string, owner string) error {
+	defer s.invalidate(ctx, alias)
+	return s.Store.UpdateURL(ctx, alias, newURL, owner)
+}
+
+func (s *Store) DeleteURL(ctx context.Context, alias string, owner string) error {
+	defer s.invalidate(ctx, alias)
+	return s.Store.DeleteURL(ctx, alias, owner)
+}
+
+func (s *Store) DeleteURLs(ctx context.Context, aliases []string, owner string) ([]error, error) {
+	defer s.invalidate(ctx, aliases...)
+	return s.Store.DeleteURLs(ctx, aliases, owner)
+}
+
+func (s *Store) ImportURLs(ctx context.Context, urls []storage.URL, onConflict string) (storage.ImportStats, error) {
+	stats, err := s.Store.ImportURLs(ctx, urls, onConflict)
+
+	aliases := make([]string, len(urls))
+	for i, u := range urls {
+		aliases[i] = u.Alias
+	}
+	s.invalidate(ctx, aliases...)
+
+	return stats, err
+}
+
+func (s *Store) invalidate(ctx context.Context, aliases ...string) {
+	//the write is done, a client that went away must not leave stale entries behind
+	ctx = context.WithoutCancel(ctx)
+
+	//aliases may belong to the caller
+	aliases = slices.DeleteFunc(slices.Clone(aliases), func(alias string) bool { return alias == "" })
+	for _, tier := range s.tiers {
+		tier.Invalidate(ctx, aliases...)
+	}
+}
@@ -0,0 +1,173 @@
+// Package deadline bounds the storage operations of the server, so that requests
+// waiting for a locked or unreachable database give up instead of piling up.
+package deadline
+
+import (
+	"context"
+	"time"
+	"url-shortener/internal/storage"
+)
+
+// Options are the deadlines of storage operations; zero leaves them unbounded.
+type Options struct {
+	Read  time.Duration //of lookups, listings and stats
+	Write time.Duration //of saves, updates and deletes, batches included
+}
+
+// Store runs every operation of the wrapped storage with a deadline. Operations
+// that miss it fail with an error wrapping context.DeadlineExceeded.
+type Store struct {
+	storage.Store
+	opts Options
+}
+
+func New(store storage.Store, opts Options) *Store {
+	return &Store{Store: store, opts: opts}
+}
+
+func (s *Store) SaveURL(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (int64, error) {
+	ctx, cancel := s.write(ctx)
+	defer cancel()
+	return s.Store.SaveURL(ctx, urlToSave, alias, owner, expiresAt)
+}
+
+func (s *Store) SaveURLIfAbsent(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (storage.Saved, error) {
+	ctx, cancel := s.write(ctx)
+	defer cancel()
+	return s.Store.SaveURLIfAbsent(ctx, urlToSave, alias, owner, expiresAt)
+}
+
+func (s *Store) SaveURLWithIDAlias(ctx context.Context, urlToSave string, owner string, expiresAt time.Time, encode func(id int64) string) (storage.Saved, error) {
+	ctx, cancel := s.write(ctx)
+	defer cancel()
+	return s.Store.SaveURLWithIDAlias(ctx, urlToSave, owner, expiresAt, encode)
+}
+
+func (s *Store) SaveURLs(ctx context.Context, urls []storage.NewURL, encode func(id int64) string) ([]storage.SaveResult, error) {
+	ctx, cancel := s.write(ctx)
+	defer cancel()
+	return s.Store.SaveURLs(ctx, urls, encode)
+}
+
+func (s *Store) UpdateURL(ctx context.Context, alias string, newURL string, owner string) error {
+	ctx, cancel := s.write(ctx)
+	defer cancel()
+	return s.Store.UpdateURL(ctx, alias, newURL, owner)
+}
+
+func (s *Store) DeleteURL(ctx context.Context, alias string, owner string) error {
+	ctx, cancel := s.write(ctx)
+	defer cancel()
+	return s.Store.DeleteURL(ctx, alias, owner)
+}
+
+func (s *Store) ImportURLs(ctx context.Context, urls []storage.URL, onConflict string) (storage.ImportStats, error) {
+	ctx, cancel := s.write(ctx)
+	defer cancel()
+	return s.Store.ImportURLs(ctx, urls, onConflict)
+}
+
+func (s *Store) DeleteURLs(ctx context.Context, aliases []string, owner string) ([]error, error) {
+	ctx, cancel := s.write(ctx)
+	defer cancel()
+	return s.Store.DeleteURLs(ctx, aliases, owner)
+}
+
+func (s *Store) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	ctx, cancel := s.write(ctx)
+	defer cancel()
+	return s.Store.DeleteExpired(ctx, now)
+}
+
+func (s *Store) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	ctx, cancel := s.write(ctx)
+	defer cancel()
+	return s.Store.SaveClicks(ctx, clicks)
+}
+
+func (s *Store) SaveAPIKey(ctx context.Context, name string, keyHash string, scopes []string) (int64, error) {
+	ctx, cancel := s.write(ctx)
+	defer cancel()
+	return s.Store.SaveAPIKey(ctx, name, keyHash, scopes)
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, id int64) error {
+	ctx, cancel := s.write(ctx)
+	defer cancel()
+	return s.Store.RevokeAPIKey(ctx, id)
+}
+
+func (s *Store) GetURL(ctx context.Context, alias string) (string, error) {
+	ctx, cancel := s.read(ctx)
+	defer cancel()
+	return s.Store.GetURL(ctx, alias)
+}
+
+func (s *Store) GetURLByID(ctx context.Context, id int64) (storage.URL, error) {
+	ctx, cancel := s.read(ctx)
+	defer cancel()
+	return s.Store.GetURLByID(ctx, id)
+}
+
+func (s *Store) GetURLByAlias(ctx context.Context, alias string) (storage.URL, error) {
+	ctx, cancel := s.read(ctx)
+	defer cancel()
+	return s.Store.GetURLByAlias(ctx, alias)
+}
+
+func (s *Store) GetAliasByURL(ctx context.Context, url string, owner string) (string, error) {
+	ctx, cancel := s.read(ctx)
+	defer cancel()
+	return s.Store.GetAliasByURL(ctx, url, owner)
+}
+
+func (s *Store) GetURLOwner(ctx context.Context, alias string) (string, error) {
+	ctx, cancel := s.read(ctx)
+	defer cancel()
+	return s.Store.GetURLOwner(ctx, alias)
+}
+
+func (s *Store) ListURLs(ctx context.Context, filter storage.ListFilter) ([]storage.URL, string, error) {
+	ctx, cancel := s.read(ctx)
+	defer cancel()
+	return s.Store.ListURLs(ctx, filter)
+}
+
+func (s *Store) GetStats(ctx context.Context, alias string) (storage.Stats, error) {
+	ctx, cancel := s.read(ctx)
+	defer cancel()
+	return s.Store.GetStats(ctx, alias)
+}
+
+func (s *Store) GetAPIKeyByHash(ctx context.Context, keyHash string) (storage.APIKey, error) {
+	ctx, cancel := s.read(ctx)
+	defer cancel()
+	return s.Store.GetAPIKeyByHash(ctx, keyHash)
+}
+
+func (s *Store) ListAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	ctx, cancel := s.read(ctx)
+	defer cancel()
+	return s.Store.ListAPIKeys(ctx)
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	ctx, cancel := s.read(ctx)
+	defer cancel()
+	return s.Store.Ping(ctx)
+}
+
+func (s *Store) read(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, s.opts.Read)
+}
+
+func (s *Store) write(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, s.opts.Write)
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
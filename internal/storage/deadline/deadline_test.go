@@ -0,0 +1,64 @@
+package deadline_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/deadline"
+	"url-shortener/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newLockedStore returns a store whose database is write-locked by another
+// connection until the test ends.
+func newLockedStore(t *testing.T, opts deadline.Options) *deadline.Store {
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	db, err := sqlite.New(path, sqlite.Options{BusyTimeout: 10 * time.Second})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	_, err = db.Migrator().Up(context.Background())
+	require.NoError(t, err)
+
+	locker, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = locker.Close() })
+	tx, err := locker.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = tx.Rollback() })
+	//the lock is taken by the first write
+	_, err = tx.Exec("DELETE FROM url WHERE alias = ''")
+	require.NoError(t, err)
+
+	return deadline.New(db, opts)
+}
+
+func TestStore_LockedDatabase(t *testing.T) {
+	store := newLockedStore(t, deadline.Options{Read: time.Second, Write: 100 * time.Millisecond})
+
+	start := time.Now()
+	_, err := store.SaveURL(context.Background(), "https://example.com", "alias", "owner", time.Time{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second, "the write must not wait for the busy timeout")
+
+	//readers are not blocked by the writer in WAL mode
+	_, err = store.GetURL(context.Background(), "alias")
+	assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+}
+
+func TestStore_ClientGone(t *testing.T) {
+	store := newLockedStore(t, deadline.Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := store.SaveURL(ctx, "https://example.com", "alias", "owner", time.Time{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 2*time.Second, "the write must stop when its request is canceled")
+}
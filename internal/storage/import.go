@@ -0,0 +1,15 @@
+package storage
+
+// What ImportURLs does with urls whose alias already exists.
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictFail      = "fail"
+)
+
+// ImportStats counts the outcome of ImportURLs.
+type ImportStats struct {
+	Created     int
+	Skipped     int
+	Overwritten int
+}
@@ -0,0 +1,216 @@
+// Package instrumented traces storage operations and measures their latency.
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"time"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/storage"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Operations are named like the methods of storage.Store, as are the ops of the backends.
+const (
+	opSaveURL            = "SaveURL"
+	opSaveURLIfAbsent    = "SaveURLIfAbsent"
+	opSaveURLWithIDAlias = "SaveURLWithIDAlias"
+	opSaveURLs           = "SaveURLs"
+	opGetURL             = "GetURL"
+	opGetURLByID         = "GetURLByID"
+	opGetURLByAlias      = "GetURLByAlias"
+	opGetAliasByURL      = "GetAliasByURL"
+	opGetURLOwner        = "GetURLOwner"
+	opUpdateURL          = "UpdateURL"
+	opListURLs           = "ListURLs"
+	opDeleteURL          = "DeleteURL"
+	opImportURLs         = "ImportURLs"
+	opDeleteURLs         = "DeleteURLs"
+	opSaveAPIKey         = "SaveAPIKey"
+	opGetAPIKeyByHash    = "GetAPIKeyByHash"
+	opListAPIKeys        = "ListAPIKeys"
+	opRevokeAPIKey       = "RevokeAPIKey"
+	opDeleteExpired      = "DeleteExpired"
+	opSaveClicks         = "SaveClicks"
+	opGetStats           = "GetStats"
+)
+
+// Store wraps every url, api key and analytics operation of a backend in a child
+// span of the ctx it is given, and records its duration. Spans and metrics are
+// named after the op of the backend, e.g. "storage.sqlite.GetURL".
+// Migrator, Ping and Close are passed through as they are.
+type Store struct {
+	storage.Store
+	driver string
+	prefix string
+}
+
+// New wraps store; driver is the name of its backend.
+func New(store storage.Store, driver string) *Store {
+	return &Store{Store: store, driver: driver, prefix: "storage." + driver + "."}
+}
+
+// start starts the span of an operation and returns the func that ends it with
+// the error of the operation. Misses and conflicts are regular outcomes that the
+// handlers turn into responses, so only other errors count as failures.
+func (s *Store) start(ctx context.Context, operation string) (context.Context, func(err error)) {
+	name := s.prefix + operation
+	ctx, span := tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system.name", s.driver)),
+	)
+	start := time.Now()
+
+	return ctx, func(err error) {
+		result := metrics.ResultOK
+		if err != nil && !isOutcome(err) {
+			result = metrics.ResultError
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		metrics.StorageDuration.WithLabelValues(name, result).Observe(time.Since(start).Seconds())
+		span.End()
+	}
+}
+
+func isOutcome(err error) bool {
+	for _, target := range []error{
+		storage.ErrUrlNotFound, storage.ErrURLExists, storage.ErrURLExpired, storage.ErrNotOwner,
+		storage.ErrAPIKeyNotFound, storage.ErrAPIKeyExists,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) SaveURL(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (id int64, err error) {
+	ctx, end := s.start(ctx, opSaveURL)
+	defer func() { end(err) }()
+	return s.Store.SaveURL(ctx, urlToSave, alias, owner, expiresAt)
+}
+
+func (s *Store) SaveURLIfAbsent(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (saved storage.Saved, err error) {
+	ctx, end := s.start(ctx, opSaveURLIfAbsent)
+	defer func() { end(err) }()
+	return s.Store.SaveURLIfAbsent(ctx, urlToSave, alias, owner, expiresAt)
+}
+
+func (s *Store) SaveURLWithIDAlias(ctx context.Context, urlToSave string, owner string, expiresAt time.Time, encode func(id int64) string) (saved storage.Saved, err error) {
+	ctx, end := s.start(ctx, opSaveURLWithIDAlias)
+	defer func() { end(err) }()
+	return s.Store.SaveURLWithIDAlias(ctx, urlToSave, owner, expiresAt, encode)
+}
+
+func (s *Store) SaveURLs(ctx context.Context, urls []storage.NewURL, encode func(id int64) string) (results []storage.SaveResult, err error) {
+	ctx, end := s.start(ctx, opSaveURLs)
+	defer func() { end(err) }()
+	return s.Store.SaveURLs(ctx, urls, encode)
+}
+
+func (s *Store) GetURL(ctx context.Context, alias string) (url string, err error) {
+	ctx, end := s.start(ctx, opGetURL)
+	defer func() { end(err) }()
+	return s.Store.GetURL(ctx, alias)
+}
+
+func (s *Store) GetURLByID(ctx context.Context, id int64) (url storage.URL, err error) {
+	ctx, end := s.start(ctx, opGetURLByID)
+	defer func() { end(err) }()
+	return s.Store.GetURLByID(ctx, id)
+}
+
+func (s *Store) GetURLByAlias(ctx context.Context, alias string) (url storage.URL, err error) {
+	ctx, end := s.start(ctx, opGetURLByAlias)
+	defer func() { end(err) }()
+	return s.Store.GetURLByAlias(ctx, alias)
+}
+
+func (s *Store) GetAliasByURL(ctx context.Context, url string, owner string) (alias string, err error) {
+	ctx, end := s.start(ctx, opGetAliasByURL)
+	defer func() { end(err) }()
+	return s.Store.GetAliasByURL(ctx, url, owner)
+}
+
+func (s *Store) GetURLOwner(ctx context.Context, alias string) (owner string, err error) {
+	ctx, end := s.start(ctx, opGetURLOwner)
+	defer func() { end(err) }()
+	return s.Store.GetURLOwner(ctx, alias)
+}
+
+func (s *Store) UpdateURL(ctx context.Context, alias string, newURL string, owner string) (err error) {
+	ctx, end := s.start(ctx, opUpdateURL)
+	defer func() { end(err) }()
+	return s.Store.UpdateURL(ctx, alias, newURL, owner)
+}
+
+func (s *Store) ListURLs(ctx context.Context, filter storage.ListFilter) (urls []storage.URL, next string, err error) {
+	ctx, end := s.start(ctx, opListURLs)
+	defer func() { end(err) }()
+	return s.Store.ListURLs(ctx, filter)
+}
+
+func (s *Store) DeleteURL(ctx context.Context, alias string, owner string) (err error) {
+	ctx, end := s.start(ctx, opDeleteURL)
+	defer func() { end(err) }()
+	return s.Store.DeleteURL(ctx, alias, owner)
+}
+
+func (s *Store) ImportURLs(ctx context.Context, urls []storage.URL, onConflict string) (stats storage.ImportStats, err error) {
+	ctx, end := s.start(ctx, opImportURLs)
+	defer func() { end(err) }()
+	return s.Store.ImportURLs(ctx, urls, onConflict)
+}
+
+func (s *Store) DeleteURLs(ctx context.Context, aliases []string, owner string) (errs []error, err error) {
+	ctx, end := s.start(ctx, opDeleteURLs)
+	defer func() { end(err) }()
+	return s.Store.DeleteURLs(ctx, aliases, owner)
+}
+
+func (s *Store) SaveAPIKey(ctx context.Context, name string, keyHash string, scopes []string) (id int64, err error) {
+	ctx, end := s.start(ctx, opSaveAPIKey)
+	defer func() { end(err) }()
+	return s.Store.SaveAPIKey(ctx, name, keyHash, scopes)
+}
+
+func (s *Store) GetAPIKeyByHash(ctx context.Context, keyHash string) (key storage.APIKey, err error) {
+	ctx, end := s.start(ctx, opGetAPIKeyByHash)
+	defer func() { end(err) }()
+	return s.Store.GetAPIKeyByHash(ctx, keyHash)
+}
+
+func (s *Store) ListAPIKeys(ctx context.Context) (keys []storage.APIKey, err error) {
+	ctx, end := s.start(ctx, opListAPIKeys)
+	defer func() { end(err) }()
+	return s.Store.ListAPIKeys(ctx)
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	ctx, end := s.start(ctx, opRevokeAPIKey)
+	defer func() { end(err) }()
+	return s.Store.RevokeAPIKey(ctx, id)
+}
+
+func (s *Store) DeleteExpired(ctx context.Context, now time.Time) (deleted int64, err error) {
+	ctx, end := s.start(ctx, opDeleteExpired)
+	defer func() { end(err) }()
+	return s.Store.DeleteExpired(ctx, now)
+}
+
+func (s *Store) SaveClicks(ctx context.Context, clicks []storage.Click) (err error) {
+	ctx, end := s.start(ctx, opSaveClicks)
+	defer func() { end(err) }()
+	return s.Store.SaveClicks(ctx, clicks)
+}
+
+func (s *Store) GetStats(ctx context.Context, alias string) (stats storage.Stats, err error) {
+	ctx, end := s.start(ctx, opGetStats)
+	defer func() { end(err) }()
+	return s.Store.GetStats(ctx, alias)
+}
@@ -0,0 +1,90 @@
+package instrumented_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/instrumented"
+	"url-shortener/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestStore_SpanNames(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	db, err := sqlite.New(filepath.Join(t.TempDir(), "storage.db"), sqlite.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	ctx := context.Background()
+	_, err = db.Migrator().Up(ctx)
+	require.NoError(t, err)
+
+	store := instrumented.New(db, "sqlite")
+	encode := func(id int64) string { return "id" + strconv.FormatInt(id, 10) }
+
+	//misses and conflicts are fine, only the span of each call matters
+	calls := map[string]func(){
+		"SaveURL": func() { _, _ = store.SaveURL(ctx, "https://example.com", "ex", "alice", time.Time{}) },
+		"SaveURLIfAbsent": func() {
+			_, _ = store.SaveURLIfAbsent(ctx, "https://example.com", "ex", "alice", time.Time{})
+		},
+		"SaveURLWithIDAlias": func() {
+			_, _ = store.SaveURLWithIDAlias(ctx, "https://example.org", "alice", time.Time{}, encode)
+		},
+		"SaveURLs": func() {
+			_, _ = store.SaveURLs(ctx, []storage.NewURL{{URL: "https://example.net", Owner: "alice"}}, encode)
+		},
+		"GetURL":          func() { _, _ = store.GetURL(ctx, "ex") },
+		"GetURLByID":      func() { _, _ = store.GetURLByID(ctx, 1) },
+		"GetURLByAlias":   func() { _, _ = store.GetURLByAlias(ctx, "ex") },
+		"GetAliasByURL":   func() { _, _ = store.GetAliasByURL(ctx, "https://example.com", "alice") },
+		"GetURLOwner":     func() { _, _ = store.GetURLOwner(ctx, "ex") },
+		"UpdateURL":       func() { _ = store.UpdateURL(ctx, "ex", "https://example.com/new", "alice") },
+		"ListURLs":        func() { _, _, _ = store.ListURLs(ctx, storage.ListFilter{Limit: 10}) },
+		"DeleteURL":       func() { _ = store.DeleteURL(ctx, "ex", "alice") },
+		"ImportURLs":      func() { _, _ = store.ImportURLs(ctx, nil, storage.ConflictSkip) },
+		"DeleteURLs":      func() { _, _ = store.DeleteURLs(ctx, []string{"ex"}, "alice") },
+		"SaveAPIKey":      func() { _, _ = store.SaveAPIKey(ctx, "ci", "hash", nil) },
+		"GetAPIKeyByHash": func() { _, _ = store.GetAPIKeyByHash(ctx, "hash") },
+		"ListAPIKeys":     func() { _, _ = store.ListAPIKeys(ctx) },
+		"RevokeAPIKey":    func() { _ = store.RevokeAPIKey(ctx, 1) },
+		"DeleteExpired":   func() { _, _ = store.DeleteExpired(ctx, time.Now()) },
+		"SaveClicks":      func() { _ = store.SaveClicks(ctx, nil) },
+		"GetStats":        func() { _, _ = store.GetStats(ctx, "ex") },
+	}
+
+	//every operation of storage.Store is covered, except the ones passed through
+	passedThrough := map[string]bool{"Migrator": true, "Ping": true, "Close": true}
+	methods := reflect.TypeFor[storage.Store]()
+	for i := range methods.NumMethod() {
+		name := methods.Method(i).Name
+		if passedThrough[name] {
+			continue
+		}
+		call, ok := calls[name]
+		if !assert.True(t, ok, "no call of %s", name) {
+			continue
+		}
+
+		exporter.Reset()
+		call()
+
+		spans := exporter.GetSpans()
+		if assert.Len(t, spans, 1, name) {
+			assert.Equal(t, "storage.sqlite."+name, spans[0].Name)
+		}
+	}
+	assert.Len(t, calls, methods.NumMethod()-len(passedThrough))
+}
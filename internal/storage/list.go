@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	SortCreatedAt = "created_at"
+	SortAlias     = "alias"
+)
+
+// ListFilter selects and orders urls for ListURLs. Zero values disable a filter.
+type ListFilter struct {
+	Owner       string
+	URLContains string
+	// Domain matches the host of the url and its subdomains.
+	Domain      string
+	AliasPrefix string
+	CreatedFrom time.Time // inclusive
+	CreatedTo   time.Time // exclusive
+	Sort        string    // SortCreatedAt or SortAlias
+	Desc        bool
+	Limit       int
+	Cursor      string // NextCursor of the previous page
+}
+
+// Cursor is the position after the last url of a page: its sort key and id.
+type Cursor struct {
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func EncodeCursor(c Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// CursorAfter returns the cursor pointing past u for the given sort.
+func CursorAfter(u URL, sort string) string {
+	if sort == SortAlias {
+		return EncodeCursor(Cursor{Value: u.Alias, ID: u.ID})
+	}
+	return EncodeCursor(Cursor{Value: u.CreatedAt.UTC().Format(time.RFC3339Nano), ID: u.ID})
+}
+
+// Domain is the lowercase host of rawURL without port, or "" if it cannot be parsed.
+func Domain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// EscapeLike escapes the LIKE wildcards of s; queries must use ESCAPE '\'.
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
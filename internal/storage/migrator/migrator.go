@@ -0,0 +1,370 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrNoMigrations = errors.New("no migrations to roll back")
+)
+
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	Postgres
+)
+
+// Migration is a pair of NNNN_name.up.sql / NNNN_name.down.sql files.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// lockKey is the Postgres advisory lock held while migrations are applied.
+const lockKey = 0x75726c5f6d6967 //"url_mig"
+
+// querier runs statements on a connection or in a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// New loads the migrations found in the root of fsys.
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	const op = "storage.migrator.New"
+
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, file := range files {
+		name := path.Base(file)
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql suffix", name)
+		}
+
+		versionStr, title, ok := strings.Cut(strings.TrimSuffix(name, "."+direction+".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name prefix", name)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", name, err)
+		}
+
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if m.Name != title {
+			return nil, fmt.Errorf("migration %d: conflicting names %q and %q", version, m.Name, title)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d: both up and down files are required", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies every pending migration in order. Concurrent migrators, e.g. replicas
+// started with auto_migrate, wait for each other, so that every migration is
+// applied once: on Postgres each migration has a transaction of its own, on SQLite
+// they share the write transaction that serves as the lock.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	const op = "storage.migrator.Up"
+
+	var done []Migration
+	err := m.locked(ctx, func(q querier, step stepFunc) error {
+		applied, err := m.applied(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			err := step(func(q querier) error {
+				if _, err := q.ExecContext(ctx, mig.Up); err != nil {
+					return err
+				}
+				_, err := q.ExecContext(ctx, m.rebind("INSERT INTO schema_migrations(version, name, applied_at) VALUES(?, ?, ?)"),
+					mig.Version, mig.Name, time.Now().UTC())
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+	if err != nil {
+		if m.dialect == SQLite {
+			//rolled back with the shared transaction
+			done = nil
+		}
+		return done, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	const op = "storage.migrator.Down"
+
+	var rolledBack Migration
+	err := m.locked(ctx, func(q querier, step stepFunc) error {
+		applied, err := m.applied(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+
+			err := step(func(q querier) error {
+				if _, err := q.ExecContext(ctx, mig.Down); err != nil {
+					return err
+				}
+				_, err := q.ExecContext(ctx, m.rebind("DELETE FROM schema_migrations WHERE version = ?"), mig.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			rolledBack = mig
+			return nil
+		}
+
+		return ErrNoMigrations
+	})
+	if err != nil {
+		return Migration{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rolledBack, nil
+}
+
+// Status reports every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	const op = "storage.migrator.Status"
+
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		appliedAt, ok := applied[mig.Version]
+		statuses = append(statuses, Status{Migration: mig, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the number of migrations that have not been applied yet.
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	const op = "storage.migrator.Pending"
+
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pending := 0
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending++
+		}
+	}
+
+	return pending, nil
+}
+
+// applied creates the bookkeeping table if needed and returns applied versions with their time.
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int]time.Time, error) {
+	_, err := q.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations(
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// stepFunc applies one migration through fn.
+type stepFunc func(fn func(q querier) error) error
+
+// locked runs fn on a connection while no other migrator changes the schema, so
+// that the migrations fn reads as pending are still pending when it applies them.
+// Postgres holds an advisory lock and runs every step in a transaction of its own;
+// SQLite has no such lock, so the steps share a write transaction instead.
+func (m *Migrator) locked(ctx context.Context, fn func(q querier, step stepFunc) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	//the lock must be released even when ctx is done
+	cleanupCtx := context.WithoutCancel(ctx)
+
+	if m.dialect == Postgres {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+			return err
+		}
+		defer func() { _, _ = conn.ExecContext(cleanupCtx, "SELECT pg_advisory_unlock($1)", lockKey) }()
+
+		return fn(conn, func(step func(q querier) error) error {
+			return inTx(ctx, conn, step)
+		})
+	}
+
+	//another migrator holds the write lock until it is done
+	for {
+		_, err = conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+		var sqliteErr sqlite3.Error
+		if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrBusy {
+			break
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	err = fn(conn, func(step func(q querier) error) error {
+		return step(conn)
+	})
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "COMMIT")
+	}
+	if err != nil {
+		_, _ = conn.ExecContext(cleanupCtx, "ROLLBACK")
+		return err
+	}
+	return nil
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(q querier) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// rebind turns ? placeholders into the dialect's syntax.
+func (m *Migrator) rebind(query string) string {
+	if m.dialect != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
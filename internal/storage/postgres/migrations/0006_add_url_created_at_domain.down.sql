@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_url_alias_pattern;
+DROP INDEX IF EXISTS idx_url_domain;
+DROP INDEX IF EXISTS idx_url_owner_created_at;
+DROP INDEX IF EXISTS idx_url_created_at;
+ALTER TABLE url DROP COLUMN IF EXISTS domain;
+ALTER TABLE url DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,9 @@
+ALTER TABLE url ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE url ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+
+UPDATE url SET domain = lower(coalesce(substring(url from '://([^/?#:]+)'), ''));
+
+CREATE INDEX IF NOT EXISTS idx_url_created_at ON url(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_url_owner_created_at ON url(owner, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_url_domain ON url(domain);
+CREATE INDEX IF NOT EXISTS idx_url_alias_pattern ON url(alias text_pattern_ops);
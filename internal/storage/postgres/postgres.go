@@ -0,0 +1,848 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/migrator"
+
+	"github.com/lib/pq"
+)
+
+const errCodeUniqueViolation = "23505"
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrations, _ = fs.Sub(migrationFiles, "migrations")
+
+type Storage struct {
+	db       *sql.DB
+	migrator *migrator.Migrator
+}
+
+func New(dsn string) (*Storage, error) {
+	const op = "storage.postgres.New"
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := migrator.New(db, migrator.Postgres, migrations)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{db: db, migrator: m}, nil
+}
+
+// Migrator manages the schema of the database; New does not apply any migrations.
+func (s *Storage) Migrator() *migrator.Migrator {
+	return s.migrator
+}
+
+// Ping checks that the database is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	const op = "storage.postgres.Ping"
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Close closes the database. The storage must not be used afterwards.
+func (s *Storage) Close() error {
+	const op = "storage.postgres.Close"
+
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveURL stores the url under alias on behalf of owner. A zero expiresAt means the link never expires.
+func (s *Storage) SaveURL(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (int64, error) {
+	const op = "storage.postgres.SaveURL"
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, `INSERT INTO url(url, alias, owner, created_at, domain, expires_at)
+		VALUES($1, $2, $3, $4, $5, $6) RETURNING id`,
+		urlToSave, alias, owner, time.Now().UTC(), storage.Domain(urlToSave), nullTime(expiresAt)).Scan(&id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == errCodeUniqueViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// SaveURLIfAbsent stores the url under alias unless owner already has a live,
+// non-expiring link to it, see storage.URLStore.
+func (s *Storage) SaveURLIfAbsent(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (storage.Saved, error) {
+	const op = "storage.postgres.SaveURLIfAbsent"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.Saved{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	saved, err := insertURL(ctx, tx, storage.NewURL{URL: urlToSave, Alias: alias, Owner: owner, ExpiresAt: expiresAt})
+	if err != nil {
+		return storage.Saved{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storage.Saved{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return saved, nil
+}
+
+// SaveURLWithIDAlias stores the url under the alias derived from its new row id.
+// Ids whose alias is already taken by a custom alias are skipped.
+func (s *Storage) SaveURLWithIDAlias(ctx context.Context, urlToSave string, owner string, expiresAt time.Time, encode func(id int64) string) (storage.Saved, error) {
+	const op = "storage.postgres.SaveURLWithIDAlias"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.Saved{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	saved, err := insertURLWithIDAlias(ctx, tx, storage.NewURL{URL: urlToSave, Owner: owner, ExpiresAt: expiresAt}, encode)
+	if err != nil {
+		return storage.Saved{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storage.Saved{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return saved, nil
+}
+
+// SaveURLs stores urls in one transaction, see storage.URLStore. Every
+// non-expiring url holds an advisory lock until commit, so batches should stay
+// well below max_locks_per_transaction.
+func (s *Storage) SaveURLs(ctx context.Context, urls []storage.NewURL, encode func(id int64) string) ([]storage.SaveResult, error) {
+	const op = "storage.postgres.SaveURLs"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	results := make([]storage.SaveResult, len(urls))
+	for i, u := range urls {
+		var saved storage.Saved
+		if u.Alias == "" && encode != nil {
+			saved, err = insertURLWithIDAlias(ctx, tx, u, encode)
+		} else {
+			saved, err = insertURL(ctx, tx, u)
+		}
+		if err != nil && !errors.Is(err, storage.ErrURLExists) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		results[i] = storage.SaveResult{Saved: saved, Err: err}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return results, nil
+}
+
+// insertURL is SaveURLIfAbsent within tx. A taken alias is skipped instead of
+// raised, so that it does not abort tx.
+func insertURL(ctx context.Context, tx *sql.Tx, u storage.NewURL) (storage.Saved, error) {
+	saved, err := existingURL(ctx, tx, u.URL, u.Owner, u.ExpiresAt)
+	if !errors.Is(err, storage.ErrUrlNotFound) {
+		return saved, err
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `INSERT INTO url(url, alias, owner, created_at, domain, expires_at)
+		VALUES($1, $2, $3, $4, $5, $6) ON CONFLICT (alias) DO NOTHING RETURNING id`,
+		u.URL, u.Alias, u.Owner, time.Now().UTC(), storage.Domain(u.URL), nullTime(u.ExpiresAt)).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Saved{}, storage.ErrURLExists
+	}
+	if err != nil {
+		return storage.Saved{}, err
+	}
+
+	return storage.Saved{ID: id, Alias: u.Alias, Created: true}, nil
+}
+
+// insertURLWithIDAlias is SaveURLWithIDAlias within tx.
+func insertURLWithIDAlias(ctx context.Context, tx *sql.Tx, u storage.NewURL, encode func(id int64) string) (storage.Saved, error) {
+	const maxAttempts = 10
+
+	saved, err := existingURL(ctx, tx, u.URL, u.Owner, u.ExpiresAt)
+	if !errors.Is(err, storage.ErrUrlNotFound) {
+		return saved, err
+	}
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		var id int64
+		if err := tx.QueryRowContext(ctx, "SELECT nextval(pg_get_serial_sequence('url', 'id'))").Scan(&id); err != nil {
+			return storage.Saved{}, err
+		}
+
+		alias := encode(id)
+		res, err := tx.ExecContext(ctx, `INSERT INTO url(id, url, alias, owner, created_at, domain, expires_at)
+			VALUES($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING`,
+			id, u.URL, alias, u.Owner, time.Now().UTC(), storage.Domain(u.URL), nullTime(u.ExpiresAt))
+		if err != nil {
+			return storage.Saved{}, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return storage.Saved{}, err
+		} else if n == 0 {
+			continue
+		}
+
+		return storage.Saved{ID: id, Alias: alias, Created: true}, nil
+	}
+
+	return storage.Saved{}, storage.ErrURLExists
+}
+
+// existingURL returns the live link owner already has to url. Links saved with
+// an expiry are always issued anew, so for them it reports storage.ErrUrlNotFound.
+// Otherwise it holds a lock on (url, owner) until tx ends, so concurrent saves of
+// the same url wait for each other instead of both inserting.
+func existingURL(ctx context.Context, tx *sql.Tx, url string, owner string, expiresAt time.Time) (storage.Saved, error) {
+	if !expiresAt.IsZero() {
+		return storage.Saved{}, storage.ErrUrlNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtextextended($1::text || ' ' || $2::text, 0))", owner, url); err != nil {
+		return storage.Saved{}, err
+	}
+
+	var saved storage.Saved
+	err := tx.QueryRowContext(ctx, `SELECT id, alias FROM url WHERE url = $1 AND owner = $2
+		AND (expires_at IS NULL OR expires_at > now()) LIMIT 1`, url, owner).
+		Scan(&saved.ID, &saved.Alias)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Saved{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.Saved{}, err
+	}
+
+	return saved, nil
+}
+
+// GetURLByID returns the url row with id, expired or not.
+func (s *Storage) GetURLByID(ctx context.Context, id int64) (storage.URL, error) {
+	const op = "storage.postgres.GetURLByID"
+
+	u := storage.URL{ID: id}
+	var expiresAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, "SELECT alias, url, owner, created_at, expires_at FROM url WHERE id = $1", id).
+		Scan(&u.Alias, &u.URL, &u.Owner, &u.CreatedAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.URL{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.URL{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if expiresAt.Valid {
+		u.ExpiresAt = &expiresAt.Time
+	}
+
+	return u, nil
+}
+
+// GetURLByAlias returns the url row with alias, expired or not.
+func (s *Storage) GetURLByAlias(ctx context.Context, alias string) (storage.URL, error) {
+	const op = "storage.postgres.GetURLByAlias"
+
+	u := storage.URL{Alias: alias}
+	var expiresAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, "SELECT id, url, owner, created_at, expires_at FROM url WHERE alias = $1", alias).
+		Scan(&u.ID, &u.URL, &u.Owner, &u.CreatedAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.URL{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.URL{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if expiresAt.Valid {
+		u.ExpiresAt = &expiresAt.Time
+	}
+
+	return u, nil
+}
+
+func (s *Storage) GetURL(ctx context.Context, alias string) (string, error) {
+	const op = "storage.postgres.GetURL"
+
+	var resUrl string
+	var expiresAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, "SELECT url, expires_at FROM url WHERE alias = $1", alias).Scan(&resUrl, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if expiresAt.Valid && !time.Now().Before(expiresAt.Time) {
+		return "", storage.ErrURLExpired
+	}
+
+	return resUrl, nil
+}
+
+func (s *Storage) DeleteURL(ctx context.Context, alias string, owner string) error {
+	const op = "storage.postgres.DeleteURL"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkOwner(ctx, tx, alias, owner); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM url WHERE alias = $1", alias); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// a later link reusing the alias must start with clean stats
+	if _, err := tx.ExecContext(ctx, "DELETE FROM click WHERE alias = $1", alias); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// checkOwner locks the row of alias until tx ends and reports whether it exists and,
+// unless owner is empty, belongs to owner: ErrUrlNotFound or ErrNotOwner otherwise.
+func checkOwner(ctx context.Context, tx *sql.Tx, alias string, owner string) error {
+	var urlOwner string
+	err := tx.QueryRowContext(ctx, "SELECT owner FROM url WHERE alias = $1 FOR UPDATE", alias).Scan(&urlOwner)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return storage.ErrUrlNotFound
+	case err != nil:
+		return err
+	case owner != "" && urlOwner != owner:
+		return storage.ErrNotOwner
+	}
+
+	return nil
+}
+
+// ImportURLs stores urls as they are, see storage.URLStore. Urls without
+// a creation time are created now.
+func (s *Storage) ImportURLs(ctx context.Context, urls []storage.URL, onConflict string) (storage.ImportStats, error) {
+	const op = "storage.postgres.ImportURLs"
+
+	var stats storage.ImportStats
+	switch onConflict {
+	case storage.ConflictSkip, storage.ConflictOverwrite, storage.ConflictFail:
+	default:
+		return storage.ImportStats{}, fmt.Errorf("%s: unknown conflict mode %q", op, onConflict)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+	for _, u := range urls {
+		createdAt := u.CreatedAt.UTC()
+		if u.CreatedAt.IsZero() {
+			createdAt = now
+		}
+		var expiresAt time.Time
+		if u.ExpiresAt != nil {
+			expiresAt = u.ExpiresAt.UTC()
+		}
+
+		res, err := tx.ExecContext(ctx, `INSERT INTO url(url, alias, owner, created_at, domain, expires_at)
+			VALUES($1, $2, $3, $4, $5, $6) ON CONFLICT (alias) DO NOTHING`,
+			u.URL, u.Alias, u.Owner, createdAt, storage.Domain(u.URL), nullTime(expiresAt))
+		if err != nil {
+			return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+		} else if n == 1 {
+			stats.Created++
+			continue
+		}
+
+		switch onConflict {
+		case storage.ConflictSkip:
+			stats.Skipped++
+		case storage.ConflictFail:
+			return storage.ImportStats{}, fmt.Errorf("%s: alias %q: %w", op, u.Alias, storage.ErrURLExists)
+		case storage.ConflictOverwrite:
+			//clicks stay with the alias, like with UpdateURL
+			_, err := tx.ExecContext(ctx, `UPDATE url SET url = $1, owner = $2, created_at = $3, domain = $4, expires_at = $5
+				WHERE alias = $6`,
+				u.URL, u.Owner, createdAt, storage.Domain(u.URL), nullTime(expiresAt), u.Alias)
+			if err != nil {
+				return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+			}
+			stats.Overwritten++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// DeleteURLs deletes aliases in one transaction, see storage.URLStore.
+func (s *Storage) DeleteURLs(ctx context.Context, aliases []string, owner string) ([]error, error) {
+	const op = "storage.postgres.DeleteURLs"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	errs := make([]error, len(aliases))
+	for i, alias := range aliases {
+		err := checkOwner(ctx, tx, alias, owner)
+		if errors.Is(err, storage.ErrUrlNotFound) || errors.Is(err, storage.ErrNotOwner) {
+			errs[i] = err
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM url WHERE alias = $1", alias); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM click WHERE alias = $1", alias); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return errs, nil
+}
+
+// GetAliasByURL returns an alias of url saved by owner.
+func (s *Storage) GetAliasByURL(ctx context.Context, url string, owner string) (string, error) {
+	const op = "storage.postgres.GetAliasByURL"
+
+	// expired links are about to be reaped, so they must not be handed out again
+	var resAlias string
+	err := s.db.QueryRowContext(ctx, `SELECT alias FROM url WHERE url = $1 AND owner = $2
+		AND (expires_at IS NULL OR expires_at > now()) LIMIT 1`, url, owner).
+		Scan(&resAlias)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return resAlias, nil
+}
+
+// UpdateURL points an existing alias to newURL, keeping its id, owner, timestamps and stats.
+func (s *Storage) UpdateURL(ctx context.Context, alias string, newURL string, owner string) error {
+	const op = "storage.postgres.UpdateURL"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkOwner(ctx, tx, alias, owner); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE url SET url = $1, domain = $2 WHERE alias = $3",
+		newURL, storage.Domain(newURL), alias); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetURLOwner(ctx context.Context, alias string) (string, error) {
+	const op = "storage.postgres.GetURLOwner"
+
+	var owner string
+	err := s.db.QueryRowContext(ctx, "SELECT owner FROM url WHERE alias = $1", alias).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return owner, nil
+}
+
+// ListURLs returns a page of urls matching filter, ordered by the sort key and id,
+// and the cursor of the next page.
+func (s *Storage) ListURLs(ctx context.Context, filter storage.ListFilter) ([]storage.URL, string, error) {
+	const op = "storage.postgres.ListURLs"
+
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if filter.Owner != "" {
+		where = append(where, "owner = "+arg(filter.Owner))
+	}
+	if filter.URLContains != "" {
+		where = append(where, "url ILIKE "+arg("%"+storage.EscapeLike(filter.URLContains)+"%")+` ESCAPE '\'`)
+	}
+	if filter.Domain != "" {
+		domain := strings.ToLower(filter.Domain)
+		where = append(where, "(domain = "+arg(domain)+" OR domain LIKE "+arg("%."+storage.EscapeLike(domain))+` ESCAPE '\')`)
+	}
+	if filter.AliasPrefix != "" {
+		// served by the text_pattern_ops index on alias
+		where = append(where, "alias LIKE "+arg(storage.EscapeLike(filter.AliasPrefix)+"%")+` ESCAPE '\'`)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		where = append(where, "created_at >= "+arg(filter.CreatedFrom.UTC()))
+	}
+	if !filter.CreatedTo.IsZero() {
+		where = append(where, "created_at < "+arg(filter.CreatedTo.UTC()))
+	}
+
+	sortColumn := "created_at"
+	if filter.Sort == storage.SortAlias {
+		sortColumn = "alias"
+	}
+	cmp, direction := ">", "ASC"
+	if filter.Desc {
+		cmp, direction = "<", "DESC"
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := storage.DecodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		var value any = cursor.Value
+		if sortColumn == "created_at" {
+			t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return nil, "", fmt.Errorf("%s: %w", op, storage.ErrInvalidCursor)
+			}
+			value = t.UTC()
+		}
+
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[4]s AND id %[2]s %[5]s))",
+			sortColumn, cmp, arg(value), arg(value), arg(cursor.ID)))
+	}
+
+	query := "SELECT id, alias, url, owner, created_at, expires_at FROM url"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// one extra row tells whether there is a next page
+	query += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s LIMIT %[3]s", sortColumn, direction, arg(filter.Limit+1))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	urls := []storage.URL{}
+	for rows.Next() {
+		var u storage.URL
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Alias, &u.URL, &u.Owner, &u.CreatedAt, &expiresAt); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+		if expiresAt.Valid {
+			u.ExpiresAt = &expiresAt.Time
+		}
+		urls = append(urls, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var next string
+	if len(urls) > filter.Limit {
+		urls = urls[:filter.Limit]
+		next = storage.CursorAfter(urls[len(urls)-1], filter.Sort)
+	}
+
+	return urls, next, nil
+}
+
+// DeleteExpired removes every url whose expiration time is not after now
+// and returns the number of deleted rows.
+func (s *Storage) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.postgres.DeleteExpired"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM click WHERE alias IN
+		(SELECT alias FROM url WHERE expires_at IS NOT NULL AND expires_at <= $1)`, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM url WHERE expires_at IS NOT NULL AND expires_at <= $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected, nil
+}
+
+// SaveClicks inserts a batch of click events in a single transaction.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	const op = "storage.postgres.SaveClicks"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("click", "alias", "clicked_at", "referrer", "user_agent", "request_id", "ip"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, c := range clicks {
+		_, err := stmt.ExecContext(ctx, c.Alias, c.ClickedAt.UTC(), c.Referrer, c.UserAgent, c.RequestID, c.IP)
+		if err != nil {
+			_ = stmt.Close()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// flush the COPY buffer
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		_ = stmt.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetStats aggregates the recorded clicks of alias.
+func (s *Storage) GetStats(ctx context.Context, alias string) (storage.Stats, error) {
+	const op = "storage.postgres.GetStats"
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM url WHERE alias = $1)", alias).Scan(&exists)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return storage.Stats{}, storage.ErrUrlNotFound
+	}
+
+	var stats storage.Stats
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*), COUNT(DISTINCT ip) FROM click WHERE alias = $1", alias).
+		Scan(&stats.TotalClicks, &stats.UniqueVisitors)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT to_char(clicked_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, COUNT(*) FROM click
+		WHERE alias = $1 GROUP BY day ORDER BY day`, alias)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	stats.Daily = []storage.DailyClicks{}
+	for rows.Next() {
+		var day storage.DailyClicks
+		if err := rows.Scan(&day.Date, &day.Clicks); err != nil {
+			return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+		}
+		stats.Daily = append(stats.Daily, day)
+	}
+	if err := rows.Err(); err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+func (s *Storage) SaveAPIKey(ctx context.Context, name string, keyHash string, scopes []string) (int64, error) {
+	const op = "storage.postgres.SaveAPIKey"
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, "INSERT INTO api_key(name, key_hash, scopes, created_at) VALUES($1, $2, $3, $4) RETURNING id",
+		name, keyHash, strings.Join(scopes, ","), time.Now().UTC()).Scan(&id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == errCodeUniqueViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, keyHash string) (storage.APIKey, error) {
+	const op = "storage.postgres.GetAPIKeyByHash"
+
+	row := s.db.QueryRowContext(ctx, `SELECT id, name, scopes, created_at, revoked_at FROM api_key
+		WHERE key_hash = $1 AND revoked_at IS NULL`, keyHash)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.APIKey{}, storage.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+func (s *Storage) ListAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	const op = "storage.postgres.ListAPIKeys"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, scopes, created_at, revoked_at FROM api_key ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	keys := []storage.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int64) error {
+	const op = "storage.postgres.RevokeAPIKey"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE api_key SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (storage.APIKey, error) {
+	var key storage.APIKey
+	var scopes string
+	var revokedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.Name, &scopes, &key.CreatedAt, &revokedAt); err != nil {
+		return storage.APIKey{}, err
+	}
+
+	key.Scopes = []string{}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return key, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t, Valid: true}
+}
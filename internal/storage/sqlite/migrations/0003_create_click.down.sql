@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_click_alias;
+DROP TABLE IF EXISTS click;
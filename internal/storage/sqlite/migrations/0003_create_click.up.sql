@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS click(
+	id INTEGER PRIMARY KEY,
+	alias TEXT NOT NULL,
+	clicked_at TIMESTAMP NOT NULL,
+	referrer TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	request_id TEXT NOT NULL DEFAULT '',
+	ip TEXT NOT NULL DEFAULT '');
+CREATE INDEX IF NOT EXISTS idx_click_alias ON click(alias, clicked_at);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS api_key(
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	key_hash TEXT NOT NULL UNIQUE,
+	scopes TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_url_owner;
+ALTER TABLE url DROP COLUMN owner;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_url_domain;
+DROP INDEX IF EXISTS idx_url_owner_created_at;
+DROP INDEX IF EXISTS idx_url_created_at;
+ALTER TABLE url DROP COLUMN domain;
+ALTER TABLE url DROP COLUMN created_at;
@@ -0,0 +1,19 @@
+ALTER TABLE url ADD COLUMN created_at TIMESTAMP;
+ALTER TABLE url ADD COLUMN domain TEXT NOT NULL DEFAULT '';
+
+-- same text format as timestamps written by the driver, so they compare correctly
+UPDATE url SET created_at = strftime('%Y-%m-%d %H:%M:%S+00:00', 'now') WHERE created_at IS NULL;
+
+-- host between "://" and the next "/"
+UPDATE url SET domain = lower(
+	CASE WHEN instr(substr(url, instr(url, '://') + 3), '/') > 0
+		THEN substr(url, instr(url, '://') + 3, instr(substr(url, instr(url, '://') + 3), '/') - 1)
+		ELSE substr(url, instr(url, '://') + 3)
+	END)
+WHERE instr(url, '://') > 0;
+UPDATE url SET domain = substr(domain, 1, instr(domain, '?') - 1) WHERE instr(domain, '?') > 0;
+UPDATE url SET domain = substr(domain, 1, instr(domain, ':') - 1) WHERE instr(domain, ':') > 0;
+
+CREATE INDEX IF NOT EXISTS idx_url_created_at ON url(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_url_owner_created_at ON url(owner, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_url_domain ON url(domain);
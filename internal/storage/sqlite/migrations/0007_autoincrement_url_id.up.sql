@@ -0,0 +1,21 @@
+-- ids, and the sequence aliases derived from them, must never be reused:
+-- sqlite can only add AUTOINCREMENT by rebuilding the table
+CREATE TABLE url_new(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	alias TEXT NOT NULL UNIQUE,
+	url TEXT NOT NULL,
+	expires_at TIMESTAMP,
+	owner TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP,
+	domain TEXT NOT NULL DEFAULT '');
+INSERT INTO url_new(id, alias, url, expires_at, owner, created_at, domain)
+	SELECT id, alias, url, expires_at, owner, created_at, domain FROM url;
+DROP TABLE url;
+ALTER TABLE url_new RENAME TO url;
+
+CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
+CREATE INDEX IF NOT EXISTS idx_expires_at ON url(expires_at);
+CREATE INDEX IF NOT EXISTS idx_url_owner ON url(owner);
+CREATE INDEX IF NOT EXISTS idx_url_created_at ON url(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_url_owner_created_at ON url(owner, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_url_domain ON url(domain);
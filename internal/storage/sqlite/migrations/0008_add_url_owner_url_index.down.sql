@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_url_owner_url;
@@ -0,0 +1,2 @@
+-- saves look up the existing link of the owner to a url
+CREATE INDEX IF NOT EXISTS idx_url_owner_url ON url(owner, url);
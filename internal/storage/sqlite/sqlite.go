@@ -1,55 +1,248 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"embed"
 	"errors"
 	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/migrator"
 
 	"github.com/mattn/go-sqlite3"
 )
 
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrations, _ = fs.Sub(migrationFiles, "migrations")
+
+// Storage keeps the data in a sqlite database in WAL mode, so that readers are not
+// blocked by the writer. Queries are prepared once per storage and reused, see Prepare.
 type Storage struct {
-	db *sql.DB
+	db       *sql.DB
+	migrator *migrator.Migrator
+
+	stmtMu sync.RWMutex
+	stmts  map[string]*sql.Stmt //by query
+
+	busyTimeout time.Duration
 }
 
-func New(storagePath string) (*Storage, error) {
+// Options tune the database connections. Zero values keep the defaults.
+type Options struct {
+	MaxOpenConns int
+	MaxIdleConns int
+	//how long a writer waits for the lock held by another one before failing, 5s by default
+	BusyTimeout time.Duration
+}
+
+const defaultBusyTimeout = 5 * time.Second
+
+// lockPoll is the busy timeout of sqlite itself. Its busy handler ignores the context
+// of the query, so writers wait for the lock in short rounds and check the context
+// in between, see retryBusy.
+const lockPoll = 50 * time.Millisecond
+
+func New(storagePath string, opts Options) (*Storage, error) {
 	const op = "storage.sqlite.New" //operation/func
 
-	db, err := sql.Open("sqlite3", storagePath)
+	//writers take the database lock when their transaction begins, so a transaction
+	//that reads before it writes cannot be invalidated by a concurrent one
+	dsn := withParam(storagePath, "_txlock", "immediate")
+	dsn = withParam(dsn, "_journal_mode", "WAL")
+	//safe in WAL mode: a power loss may only lose the last commits, not corrupt the database
+	dsn = withParam(dsn, "_synchronous", "NORMAL")
+	dsn = withParam(dsn, "_busy_timeout", strconv.FormatInt(lockPoll.Milliseconds(), 10))
+	if opts.BusyTimeout <= 0 {
+		opts.BusyTimeout = defaultBusyTimeout
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
 
-	stmt, err := db.Prepare(`
-	CREATE TABLE IF NOT EXISTS url(
-		id INTEGER PRIMARY KEY,
-		alias TEXT NOT NULL UNIQUE,
-		url TEXT NOT NULL);
-	CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
-	`)
+	m, err := migrator.New(db, migrator.SQLite, migrations)
 	if err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = stmt.Exec()
+	return &Storage{db: db, migrator: m, stmts: make(map[string]*sql.Stmt), busyTimeout: opts.BusyTimeout}, nil
+}
+
+// Migrator manages the schema of the database; New does not apply any migrations.
+func (s *Storage) Migrator() *migrator.Migrator {
+	return s.migrator
+}
+
+// Ping checks that the database is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	const op = "storage.sqlite.Ping"
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Close closes the prepared statements and the database. The storage must not be used afterwards.
+func (s *Storage) Close() error {
+	const op = "storage.sqlite.Close"
+
+	s.stmtMu.Lock()
+	var errs []error
+	for _, stmt := range s.stmts {
+		errs = append(errs, stmt.Close())
+	}
+	clear(s.stmts)
+	s.stmtMu.Unlock()
+
+	if err := errors.Join(append(errs, s.db.Close())...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Prepare prepares the statements of the queries with a fixed text, so that the
+// first requests do not wait for them. Call it once the migrations have created
+// the tables the queries read; statements that are not prepared yet are prepared
+// on first use.
+func (s *Storage) Prepare(ctx context.Context) error {
+	const op = "storage.sqlite.Prepare"
+
+	for _, query := range statements {
+		if _, err := s.prepared(ctx, nil, query); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// prepared returns the statement of query, within tx if it is not nil, and
+// prepares it if it was not prepared yet.
+func (s *Storage) prepared(ctx context.Context, tx *sql.Tx, query string) (*sql.Stmt, error) {
+	s.stmtMu.RLock()
+	stmt, ok := s.stmts[query]
+	s.stmtMu.RUnlock()
+
+	if !ok {
+		//prepared outside of the lock, so that other queries are not blocked meanwhile
+		fresh, err := s.db.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		s.stmtMu.Lock()
+		if stmt, ok = s.stmts[query]; !ok {
+			stmt = fresh
+			s.stmts[query] = stmt
+		}
+		s.stmtMu.Unlock()
+
+		if ok {
+			//a concurrent query prepared it first
+			_ = fresh.Close()
+		}
+	}
+
+	if tx != nil {
+		return tx.StmtContext(ctx, stmt), nil
+	}
+	return stmt, nil
+}
+
+func (s *Storage) exec(ctx context.Context, tx *sql.Tx, query string, args ...any) (sql.Result, error) {
+	stmt, err := s.prepared(ctx, tx, query)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, err
+	}
+	if tx != nil {
+		return stmt.ExecContext(ctx, args...)
 	}
 
-	return &Storage{db: db}, nil
+	//outside of a transaction the statement takes the write lock itself
+	var res sql.Result
+	err = s.retryBusy(ctx, func() error {
+		res, err = stmt.ExecContext(ctx, args...)
+		return err
+	})
+	return res, err
 }
 
-func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
-	const op = "storage.sqlite.SaveUrl"
+// begin starts a write transaction, which takes the write lock at once (_txlock=immediate).
+func (s *Storage) begin(ctx context.Context) (*sql.Tx, error) {
+	var tx *sql.Tx
+	err := s.retryBusy(ctx, func() error {
+		var err error
+		tx, err = s.db.BeginTx(ctx, nil)
+		return err
+	})
+	return tx, err
+}
 
-	stmt, err := s.db.Prepare("INSERT INTO url(url, alias) VALUES(?, ?)")
+// retryBusy runs fn again while it fails because another connection holds the
+// write lock, until the busy timeout passes or ctx is done.
+func (s *Storage) retryBusy(ctx context.Context, fn func() error) error {
+	deadline := time.Now().Add(s.busyTimeout)
+	for {
+		err := fn()
+		var sqliteErr sqlite3.Error
+		if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrBusy || !time.Now().Before(deadline) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Storage) query(ctx context.Context, tx *sql.Tx, query string, args ...any) (*sql.Rows, error) {
+	stmt, err := s.prepared(ctx, tx, query)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return nil, err
 	}
+	return stmt.QueryContext(ctx, args...)
+}
 
-	res, err := stmt.Exec(urlToSave, alias)
+func (s *Storage) queryRow(ctx context.Context, tx *sql.Tx, query string, args ...any) rowScanner {
+	stmt, err := s.prepared(ctx, tx, query)
+	if err != nil {
+		return errRow{err: err}
+	}
+	return stmt.QueryRowContext(ctx, args...)
+}
 
+// errRow is the row of a query that could not be prepared.
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...any) error {
+	return r.err
+}
+
+// SaveURL stores the url under alias on behalf of owner. A zero expiresAt means the link never expires.
+func (s *Storage) SaveURL(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (int64, error) {
+	const op = "storage.sqlite.SaveURL"
+
+	res, err := s.exec(ctx, nil, queryInsertURL,
+		urlToSave, alias, owner, time.Now().UTC(), storage.Domain(urlToSave), nullTime(expiresAt))
 	if err != nil {
 		if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
 			return 0, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
@@ -66,16 +259,217 @@ func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
 	return id, nil
 }
 
-func (s *Storage) GetURL(alias string) (string, error) {
-	const op = "storage.sqlite.GetUrl"
+// SaveURLIfAbsent stores the url under alias unless owner already has a live,
+// non-expiring link to it, see storage.URLStore.
+func (s *Storage) SaveURLIfAbsent(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (storage.Saved, error) {
+	const op = "storage.sqlite.SaveURLIfAbsent"
 
-	stmt, err := s.db.Prepare("SELECT url FROM url WHERE alias = ?")
+	tx, err := s.begin(ctx)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		return storage.Saved{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	saved, err := s.insertURL(ctx, tx, storage.NewURL{URL: urlToSave, Alias: alias, Owner: owner, ExpiresAt: expiresAt})
+	if err != nil {
+		return storage.Saved{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storage.Saved{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return saved, nil
+}
+
+// SaveURLWithIDAlias stores the url under the alias derived from its new row id.
+// Ids whose alias is already taken by a custom alias are skipped; ids are never
+// reused, so neither are the aliases of deleted rows.
+func (s *Storage) SaveURLWithIDAlias(ctx context.Context, urlToSave string, owner string, expiresAt time.Time, encode func(id int64) string) (storage.Saved, error) {
+	const op = "storage.sqlite.SaveURLWithIDAlias"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return storage.Saved{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	saved, err := s.insertURLWithIDAlias(ctx, tx, storage.NewURL{URL: urlToSave, Owner: owner, ExpiresAt: expiresAt}, encode)
+	if err != nil {
+		return storage.Saved{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storage.Saved{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return saved, nil
+}
+
+// SaveURLs stores urls in one transaction, see storage.URLStore.
+func (s *Storage) SaveURLs(ctx context.Context, urls []storage.NewURL, encode func(id int64) string) ([]storage.SaveResult, error) {
+	const op = "storage.sqlite.SaveURLs"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	results := make([]storage.SaveResult, len(urls))
+	for i, u := range urls {
+		var saved storage.Saved
+		if u.Alias == "" && encode != nil {
+			saved, err = s.insertURLWithIDAlias(ctx, tx, u, encode)
+		} else {
+			saved, err = s.insertURL(ctx, tx, u)
+		}
+		if err != nil && !errors.Is(err, storage.ErrURLExists) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		results[i] = storage.SaveResult{Saved: saved, Err: err}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	return results, nil
+}
+
+// insertURL is SaveURLIfAbsent within tx.
+func (s *Storage) insertURL(ctx context.Context, tx *sql.Tx, u storage.NewURL) (storage.Saved, error) {
+	saved, err := s.existingURL(ctx, tx, u.URL, u.Owner, u.ExpiresAt)
+	if !errors.Is(err, storage.ErrUrlNotFound) {
+		return saved, err
+	}
+
+	res, err := s.exec(ctx, tx, queryInsertURLIfAbsent,
+		u.URL, u.Alias, u.Owner, time.Now().UTC(), storage.Domain(u.URL), nullTime(u.ExpiresAt))
+	if err != nil {
+		return storage.Saved{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return storage.Saved{}, err
+	} else if n == 0 {
+		return storage.Saved{}, storage.ErrURLExists
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return storage.Saved{}, fmt.Errorf("%w, failed to get last generated id", err)
+	}
+
+	return storage.Saved{ID: id, Alias: u.Alias, Created: true}, nil
+}
+
+// insertURLWithIDAlias is SaveURLWithIDAlias within tx.
+func (s *Storage) insertURLWithIDAlias(ctx context.Context, tx *sql.Tx, u storage.NewURL, encode func(id int64) string) (storage.Saved, error) {
+	const maxAttempts = 10
+
+	saved, err := s.existingURL(ctx, tx, u.URL, u.Owner, u.ExpiresAt)
+	if !errors.Is(err, storage.ErrUrlNotFound) {
+		return saved, err
+	}
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		//the row takes a unique placeholder until its id is known
+		res, err := s.exec(ctx, tx, queryInsertPendingURL,
+			u.URL, u.Owner, time.Now().UTC(), storage.Domain(u.URL), nullTime(u.ExpiresAt))
+		if err != nil {
+			return storage.Saved{}, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return storage.Saved{}, fmt.Errorf("%w, failed to get last generated id", err)
+		}
+
+		alias := encode(id)
+		_, err = s.exec(ctx, tx, querySetAlias, alias, id)
+		if err == nil {
+			return storage.Saved{ID: id, Alias: alias, Created: true}, nil
+		}
+		if sqliteErr, ok := err.(sqlite3.Error); !ok || sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique {
+			return storage.Saved{}, err
+		}
+
+		//the alias of id is taken by a custom alias, skip the id
+		if _, err := s.exec(ctx, tx, queryDeleteURLByID, id); err != nil {
+			return storage.Saved{}, err
+		}
+	}
+
+	return storage.Saved{}, storage.ErrURLExists
+}
+
+// existingURL returns the live link owner already has to url. Links saved with
+// an expiry are always issued anew, so for them it reports storage.ErrUrlNotFound.
+func (s *Storage) existingURL(ctx context.Context, tx *sql.Tx, url string, owner string, expiresAt time.Time) (storage.Saved, error) {
+	if !expiresAt.IsZero() {
+		return storage.Saved{}, storage.ErrUrlNotFound
+	}
+
+	var saved storage.Saved
+	err := s.queryRow(ctx, tx, queryExistingURL, url, owner, time.Now().UTC()).
+		Scan(&saved.ID, &saved.Alias)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Saved{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.Saved{}, err
+	}
+
+	return saved, nil
+}
+
+// GetURLByID returns the url row with id, expired or not.
+func (s *Storage) GetURLByID(ctx context.Context, id int64) (storage.URL, error) {
+	const op = "storage.sqlite.GetURLByID"
+
+	u := storage.URL{ID: id}
+	var expiresAt sql.NullTime
+	err := s.queryRow(ctx, nil, queryURLByID, id).
+		Scan(&u.Alias, &u.URL, &u.Owner, &u.CreatedAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.URL{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.URL{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if expiresAt.Valid {
+		u.ExpiresAt = &expiresAt.Time
+	}
+
+	return u, nil
+}
+
+// GetURLByAlias returns the url row with alias, expired or not.
+func (s *Storage) GetURLByAlias(ctx context.Context, alias string) (storage.URL, error) {
+	const op = "storage.sqlite.GetURLByAlias"
+
+	u := storage.URL{Alias: alias}
+	var expiresAt sql.NullTime
+	err := s.queryRow(ctx, nil, queryURLByAlias, alias).
+		Scan(&u.ID, &u.URL, &u.Owner, &u.CreatedAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.URL{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.URL{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if expiresAt.Valid {
+		u.ExpiresAt = &expiresAt.Time
+	}
+
+	return u, nil
+}
+
+func (s *Storage) GetURL(ctx context.Context, alias string) (string, error) {
+	const op = "storage.sqlite.GetURL"
+
 	var resUrl string
-	err = stmt.QueryRow(alias).Scan(&resUrl)
+	var expiresAt sql.NullTime
+	err := s.queryRow(ctx, nil, queryResolveURL, alias).Scan(&resUrl, &expiresAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return "", storage.ErrUrlNotFound
 	}
@@ -83,44 +477,168 @@ func (s *Storage) GetURL(alias string) (string, error) {
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
+	if expiresAt.Valid && !time.Now().Before(expiresAt.Time) {
+		return "", storage.ErrURLExpired
+	}
+
 	return resUrl, nil
 }
 
-func (s *Storage) DeleteURL(alias string) error {
-	const op = "storage.sqlite.DeleteUrl"
+func (s *Storage) DeleteURL(ctx context.Context, alias string, owner string) error {
+	const op = "storage.sqlite.DeleteURL"
 
-	stmt, err := s.db.Prepare("DELETE FROM url WHERE alias = ?")
+	tx, err := s.begin(ctx)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	res, err := stmt.Exec(alias)
-	if err != nil {
+	if err := s.checkOwner(ctx, tx, alias, owner); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
+	if _, err := s.exec(ctx, tx, queryDeleteURL, alias); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if rowsAffected == 0 {
+	// a later link reusing the alias must start with clean stats
+	if _, err := s.exec(ctx, tx, queryDeleteClicks, alias); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// checkOwner reports whether alias exists in tx and, unless owner is empty,
+// belongs to owner: ErrUrlNotFound or ErrNotOwner otherwise. Transactions take
+// the write lock at begin, so the row cannot change before tx ends.
+func (s *Storage) checkOwner(ctx context.Context, tx *sql.Tx, alias string, owner string) error {
+	var urlOwner string
+	err := s.queryRow(ctx, tx, queryOwner, alias).Scan(&urlOwner)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
 		return storage.ErrUrlNotFound
+	case err != nil:
+		return err
+	case owner != "" && urlOwner != owner:
+		return storage.ErrNotOwner
 	}
 
 	return nil
 }
 
-func (s *Storage) GetAliasByURL(url string) (string, error) {
-	const op = "storage.sqlite.GetAliasByURL"
+// ImportURLs stores urls as they are, see storage.URLStore. Urls without
+// a creation time are created now.
+func (s *Storage) ImportURLs(ctx context.Context, urls []storage.URL, onConflict string) (storage.ImportStats, error) {
+	const op = "storage.sqlite.ImportURLs"
+
+	var stats storage.ImportStats
+	switch onConflict {
+	case storage.ConflictSkip, storage.ConflictOverwrite, storage.ConflictFail:
+	default:
+		return storage.ImportStats{}, fmt.Errorf("%s: unknown conflict mode %q", op, onConflict)
+	}
 
-	stmt, err := s.db.Prepare("SELECT alias FROM url WHERE url = ?")
+	tx, err := s.begin(ctx)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+	for _, u := range urls {
+		createdAt := u.CreatedAt.UTC()
+		if u.CreatedAt.IsZero() {
+			createdAt = now
+		}
+		var expiresAt time.Time
+		if u.ExpiresAt != nil {
+			expiresAt = u.ExpiresAt.UTC()
+		}
+
+		res, err := s.exec(ctx, tx, queryInsertURLIfAbsent,
+			u.URL, u.Alias, u.Owner, createdAt, storage.Domain(u.URL), nullTime(expiresAt))
+		if err != nil {
+			return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+		} else if n == 1 {
+			stats.Created++
+			continue
+		}
+
+		switch onConflict {
+		case storage.ConflictSkip:
+			stats.Skipped++
+		case storage.ConflictFail:
+			return storage.ImportStats{}, fmt.Errorf("%s: alias %q: %w", op, u.Alias, storage.ErrURLExists)
+		case storage.ConflictOverwrite:
+			//clicks stay with the alias, like with UpdateURL
+			_, err := s.exec(ctx, tx, queryOverwriteURL,
+				u.URL, u.Owner, createdAt, storage.Domain(u.URL), nullTime(expiresAt), u.Alias)
+			if err != nil {
+				return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
+			}
+			stats.Overwritten++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storage.ImportStats{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	return stats, nil
+}
+
+// DeleteURLs deletes aliases in one transaction, see storage.URLStore.
+func (s *Storage) DeleteURLs(ctx context.Context, aliases []string, owner string) ([]error, error) {
+	const op = "storage.sqlite.DeleteURLs"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	errs := make([]error, len(aliases))
+	for i, alias := range aliases {
+		err := s.checkOwner(ctx, tx, alias, owner)
+		if errors.Is(err, storage.ErrUrlNotFound) || errors.Is(err, storage.ErrNotOwner) {
+			errs[i] = err
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if _, err := s.exec(ctx, tx, queryDeleteURL, alias); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if _, err := s.exec(ctx, tx, queryDeleteClicks, alias); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return errs, nil
+}
+
+// GetAliasByURL returns an alias of url saved by owner.
+func (s *Storage) GetAliasByURL(ctx context.Context, url string, owner string) (string, error) {
+	const op = "storage.sqlite.GetAliasByURL"
+
+	// expired links are about to be reaped, so they must not be handed out again
 	var resAlias string
-	err = stmt.QueryRow(url).Scan(&resAlias)
+	err := s.queryRow(ctx, nil, queryAliasByURL,
+		url, owner, time.Now().UTC()).Scan(&resAlias)
 	if errors.Is(err, sql.ErrNoRows) {
 		return "", storage.ErrUrlNotFound
 	}
@@ -130,3 +648,370 @@ func (s *Storage) GetAliasByURL(url string) (string, error) {
 
 	return resAlias, nil
 }
+
+// UpdateURL points an existing alias to newURL, keeping its id, owner, timestamps and stats.
+func (s *Storage) UpdateURL(ctx context.Context, alias string, newURL string, owner string) error {
+	const op = "storage.sqlite.UpdateURL"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := s.checkOwner(ctx, tx, alias, owner); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.exec(ctx, tx, queryUpdateURL,
+		newURL, storage.Domain(newURL), alias); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetURLOwner(ctx context.Context, alias string) (string, error) {
+	const op = "storage.sqlite.GetURLOwner"
+
+	var owner string
+	err := s.queryRow(ctx, nil, queryOwner, alias).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return owner, nil
+}
+
+// ListURLs returns a page of urls matching filter, ordered by the sort key and id,
+// and the cursor of the next page.
+func (s *Storage) ListURLs(ctx context.Context, filter storage.ListFilter) ([]storage.URL, string, error) {
+	const op = "storage.sqlite.ListURLs"
+
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "?"
+	}
+
+	if filter.Owner != "" {
+		where = append(where, "owner = "+arg(filter.Owner))
+	}
+	if filter.URLContains != "" {
+		where = append(where, "url LIKE "+arg("%"+storage.EscapeLike(filter.URLContains)+"%")+` ESCAPE '\'`)
+	}
+	if filter.Domain != "" {
+		domain := strings.ToLower(filter.Domain)
+		where = append(where, "(domain = "+arg(domain)+" OR domain LIKE "+arg("%."+storage.EscapeLike(domain))+` ESCAPE '\')`)
+	}
+	if filter.AliasPrefix != "" {
+		// a range instead of LIKE, so that the unique index on alias is used
+		where = append(where, "alias >= "+arg(filter.AliasPrefix)+" AND alias < "+arg(filter.AliasPrefix+"\U0010FFFF"))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		where = append(where, "created_at >= "+arg(filter.CreatedFrom.UTC()))
+	}
+	if !filter.CreatedTo.IsZero() {
+		where = append(where, "created_at < "+arg(filter.CreatedTo.UTC()))
+	}
+
+	sortColumn := "created_at"
+	if filter.Sort == storage.SortAlias {
+		sortColumn = "alias"
+	}
+	cmp, direction := ">", "ASC"
+	if filter.Desc {
+		cmp, direction = "<", "DESC"
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := storage.DecodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		var value any = cursor.Value
+		if sortColumn == "created_at" {
+			t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return nil, "", fmt.Errorf("%s: %w", op, storage.ErrInvalidCursor)
+			}
+			value = t.UTC()
+		}
+
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[4]s AND id %[2]s %[5]s))",
+			sortColumn, cmp, arg(value), arg(value), arg(cursor.ID)))
+	}
+
+	query := "SELECT id, alias, url, owner, created_at, expires_at FROM url"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// one extra row tells whether there is a next page
+	query += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s LIMIT %[3]s", sortColumn, direction, arg(filter.Limit+1))
+
+	//the text only depends on which filters are set, so it has few variants to prepare
+	rows, err := s.query(ctx, nil, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	urls := []storage.URL{}
+	for rows.Next() {
+		var u storage.URL
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Alias, &u.URL, &u.Owner, &u.CreatedAt, &expiresAt); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+		if expiresAt.Valid {
+			u.ExpiresAt = &expiresAt.Time
+		}
+		urls = append(urls, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var next string
+	if len(urls) > filter.Limit {
+		urls = urls[:filter.Limit]
+		next = storage.CursorAfter(urls[len(urls)-1], filter.Sort)
+	}
+
+	return urls, next, nil
+}
+
+// DeleteExpired removes every url whose expiration time is not after now
+// and returns the number of deleted rows.
+func (s *Storage) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteExpired"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = s.exec(ctx, tx, queryDeleteExpiredClicks, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := s.exec(ctx, tx, queryDeleteExpiredURLs, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected, nil
+}
+
+// SaveClicks inserts a batch of click events in a single transaction.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	const op = "storage.sqlite.SaveClicks"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := s.prepared(ctx, tx, queryInsertClick)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, c := range clicks {
+		_, err := stmt.ExecContext(ctx, c.Alias, c.ClickedAt.UTC(), c.Referrer, c.UserAgent, c.RequestID, c.IP)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetStats aggregates the recorded clicks of alias.
+func (s *Storage) GetStats(ctx context.Context, alias string) (storage.Stats, error) {
+	const op = "storage.sqlite.GetStats"
+
+	var exists int
+	err := s.queryRow(ctx, nil, queryURLExists, alias).Scan(&exists)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if exists == 0 {
+		return storage.Stats{}, storage.ErrUrlNotFound
+	}
+
+	var stats storage.Stats
+	err = s.queryRow(ctx, nil, queryClickTotals, alias).
+		Scan(&stats.TotalClicks, &stats.UniqueVisitors)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.query(ctx, nil, queryDailyClicks, alias)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	stats.Daily = []storage.DailyClicks{}
+	for rows.Next() {
+		var day storage.DailyClicks
+		if err := rows.Scan(&day.Date, &day.Clicks); err != nil {
+			return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+		}
+		stats.Daily = append(stats.Daily, day)
+	}
+	if err := rows.Err(); err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+func (s *Storage) SaveAPIKey(ctx context.Context, name string, keyHash string, scopes []string) (int64, error) {
+	const op = "storage.sqlite.SaveAPIKey"
+
+	res, err := s.exec(ctx, nil, queryInsertAPIKey,
+		name, keyHash, strings.Join(scopes, ","), time.Now().UTC())
+	if err != nil {
+		if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w, failed to get last generated id", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, keyHash string) (storage.APIKey, error) {
+	const op = "storage.sqlite.GetAPIKeyByHash"
+
+	row := s.queryRow(ctx, nil, queryAPIKeyByHash, keyHash)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.APIKey{}, storage.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+func (s *Storage) ListAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	const op = "storage.sqlite.ListAPIKeys"
+
+	rows, err := s.query(ctx, nil, queryListAPIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	keys := []storage.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.RevokeAPIKey"
+
+	res, err := s.exec(ctx, nil, queryRevokeAPIKey, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (storage.APIKey, error) {
+	var key storage.APIKey
+	var scopes string
+	var revokedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.Name, &scopes, &key.CreatedAt, &revokedAt); err != nil {
+		return storage.APIKey{}, err
+	}
+
+	key.Scopes = []string{}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return key, nil
+}
+
+// nullTime stores expiration times in UTC so that they compare correctly as text.
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// withParam adds a connection parameter to the sqlite dsn unless it is set already.
+func withParam(dsn string, key string, value string) string {
+	if strings.Contains(dsn, key+"=") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + key + "=" + value
+	}
+	return dsn + "?" + key + "=" + value
+}
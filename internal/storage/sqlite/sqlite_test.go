@@ -0,0 +1,177 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/migrator"
+	"url-shortener/internal/storage/sqlite"
+	"url-shortener/internal/storage/storagetest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	store, err := sqlite.New(filepath.Join(t.TempDir(), "storage.db"), sqlite.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	_, err = store.Migrator().Up(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, store.Prepare(context.Background()))
+
+	storagetest.Run(t, store)
+}
+
+// Statements fail to prepare until the migrations create their tables, and are
+// prepared on first use if Prepare was not called.
+func TestStorage_BeforeMigrations(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := sqlite.New(filepath.Join(t.TempDir(), "storage.db"), sqlite.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	require.Error(t, store.Prepare(ctx))
+	_, err = store.GetURL(ctx, "alias")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, storage.ErrUrlNotFound)
+
+	_, err = store.Migrator().Up(context.Background())
+	require.NoError(t, err)
+
+	_, err = store.GetURL(ctx, "alias")
+	require.ErrorIs(t, err, storage.ErrUrlNotFound)
+	require.NoError(t, store.Prepare(ctx))
+}
+
+func TestMigrations_UpDown(t *testing.T) {
+	store, err := sqlite.New(filepath.Join(t.TempDir(), "storage.db"), sqlite.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	m := store.Migrator()
+	ctx := context.Background()
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, applied)
+
+	pending, err := m.Pending(ctx)
+	require.NoError(t, err)
+	require.Zero(t, pending)
+
+	for range applied {
+		_, err := m.Down(ctx)
+		require.NoError(t, err)
+	}
+
+	_, err = m.Down(ctx)
+	require.ErrorIs(t, err, migrator.ErrNoMigrations)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	for _, st := range statuses {
+		require.False(t, st.Applied)
+	}
+
+	reapplied, err := m.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, reapplied, len(applied))
+}
+
+// Migrators of replicas started at once apply every migration once.
+func TestMigrations_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	const replicas = 4
+	applied := make([][]migrator.Migration, replicas)
+	errs := make([]error, replicas)
+	var wg sync.WaitGroup
+	for i := range replicas {
+		store, err := sqlite.New(path, sqlite.Options{})
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied[i], errs[i] = store.Migrator().Up(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range replicas {
+		require.NoError(t, errs[i])
+		total += len(applied[i])
+	}
+	store, err := sqlite.New(path, sqlite.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+	all, err := store.Migrator().Status(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, len(all), total)
+}
+
+func newBenchStorage(b *testing.B) *sqlite.Storage {
+	store, err := sqlite.New(filepath.Join(b.TempDir(), "storage.db"), sqlite.Options{
+		MaxOpenConns: 16,
+		MaxIdleConns: 16,
+		BusyTimeout:  5 * time.Second,
+	})
+	require.NoError(b, err)
+	b.Cleanup(func() { require.NoError(b, store.Close()) })
+
+	_, err = store.Migrator().Up(context.Background())
+	require.NoError(b, err)
+	require.NoError(b, store.Prepare(context.Background()))
+
+	return store
+}
+
+func BenchmarkSaveURL(b *testing.B) {
+	store := newBenchStorage(b)
+	ctx := context.Background()
+	var n atomic.Int64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			alias := "alias-" + strconv.FormatInt(n.Add(1), 10)
+			if _, err := store.SaveURL(ctx, "https://example.com/"+alias, alias, "owner", time.Time{}); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkGetURL(b *testing.B) {
+	store := newBenchStorage(b)
+	ctx := context.Background()
+
+	const aliases = 1000
+	for i := range aliases {
+		alias := "alias-" + strconv.Itoa(i)
+		_, err := store.SaveURL(ctx, "https://example.com/"+alias, alias, "owner", time.Time{})
+		require.NoError(b, err)
+	}
+	var n atomic.Int64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			alias := "alias-" + strconv.FormatInt(n.Add(1)%aliases, 10)
+			if _, err := store.GetURL(ctx, alias); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
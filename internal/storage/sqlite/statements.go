@@ -0,0 +1,68 @@
+package sqlite
+
+// Queries with a fixed text, prepared by Storage.Prepare.
+const (
+	queryInsertURL = `INSERT INTO url(url, alias, owner, created_at, domain, expires_at)
+		VALUES(?, ?, ?, ?, ?, ?)`
+	queryInsertURLIfAbsent = `INSERT INTO url(url, alias, owner, created_at, domain, expires_at)
+		VALUES(?, ?, ?, ?, ?, ?) ON CONFLICT(alias) DO NOTHING`
+	queryInsertPendingURL = `INSERT INTO url(url, alias, owner, created_at, domain, expires_at)
+		VALUES(?, 'pending-' || hex(randomblob(16)), ?, ?, ?, ?)`
+	querySetAlias      = "UPDATE url SET alias = ? WHERE id = ?"
+	queryDeleteURLByID = "DELETE FROM url WHERE id = ?"
+	queryExistingURL   = `SELECT id, alias FROM url WHERE url = ? AND owner = ?
+		AND (expires_at IS NULL OR expires_at > ?) LIMIT 1`
+	queryURLByID      = "SELECT alias, url, owner, created_at, expires_at FROM url WHERE id = ?"
+	queryURLByAlias   = "SELECT id, url, owner, created_at, expires_at FROM url WHERE alias = ?"
+	queryResolveURL   = "SELECT url, expires_at FROM url WHERE alias = ?"
+	queryDeleteURL    = "DELETE FROM url WHERE alias = ?"
+	queryDeleteClicks = "DELETE FROM click WHERE alias = ?"
+	queryOwner        = "SELECT owner FROM url WHERE alias = ?"
+	queryOverwriteURL = `UPDATE url SET url = ?, owner = ?, created_at = ?, domain = ?, expires_at = ?
+		WHERE alias = ?`
+	queryAliasByURL          = "SELECT alias FROM url WHERE url = ? AND owner = ? AND (expires_at IS NULL OR expires_at > ?)"
+	queryUpdateURL           = "UPDATE url SET url = ?, domain = ? WHERE alias = ?"
+	queryDeleteExpiredClicks = `DELETE FROM click WHERE alias IN
+		(SELECT alias FROM url WHERE expires_at IS NOT NULL AND expires_at <= ?)`
+	queryDeleteExpiredURLs = "DELETE FROM url WHERE expires_at IS NOT NULL AND expires_at <= ?"
+	queryInsertClick       = `INSERT INTO click(alias, clicked_at, referrer, user_agent, request_id, ip)
+		VALUES(?, ?, ?, ?, ?, ?)`
+	queryURLExists   = "SELECT COUNT(*) FROM url WHERE alias = ?"
+	queryClickTotals = "SELECT COUNT(*), COUNT(DISTINCT ip) FROM click WHERE alias = ?"
+	queryDailyClicks = `SELECT date(clicked_at) AS day, COUNT(*) FROM click
+		WHERE alias = ? GROUP BY day ORDER BY day`
+	queryInsertAPIKey = "INSERT INTO api_key(name, key_hash, scopes, created_at) VALUES(?, ?, ?, ?)"
+	queryAPIKeyByHash = `SELECT id, name, scopes, created_at, revoked_at FROM api_key
+		WHERE key_hash = ? AND revoked_at IS NULL`
+	queryListAPIKeys  = "SELECT id, name, scopes, created_at, revoked_at FROM api_key ORDER BY id"
+	queryRevokeAPIKey = "UPDATE api_key SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL"
+)
+
+// statements are the queries prepared ahead of their first use.
+var statements = []string{
+	queryInsertURL,
+	queryInsertURLIfAbsent,
+	queryInsertPendingURL,
+	querySetAlias,
+	queryDeleteURLByID,
+	queryExistingURL,
+	queryURLByID,
+	queryURLByAlias,
+	queryResolveURL,
+	queryDeleteURL,
+	queryDeleteClicks,
+	queryOwner,
+	queryOverwriteURL,
+	queryAliasByURL,
+	queryUpdateURL,
+	queryDeleteExpiredClicks,
+	queryDeleteExpiredURLs,
+	queryInsertClick,
+	queryURLExists,
+	queryClickTotals,
+	queryDailyClicks,
+	queryInsertAPIKey,
+	queryAPIKeyByHash,
+	queryListAPIKeys,
+	queryRevokeAPIKey,
+}
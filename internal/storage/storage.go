@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/storage/migrator"
 
 	"github.com/go-chi/render"
 )
@@ -11,11 +15,167 @@ import (
 var (
 	ErrUrlNotFound = errors.New("url not found")
 	ErrURLExists   = errors.New("url already exists")
+	ErrURLExpired  = errors.New("url expired")
+	ErrNotOwner    = errors.New("url belongs to another owner")
+
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyExists   = errors.New("api key already exists")
 )
 
+// URLStore is the set of url operations the http handlers rely on.
+// Every storage backend must implement it.
+type URLStore interface {
+	SaveURL(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (int64, error)
+	// SaveURLIfAbsent atomically saves the url under alias, unless it never expires and
+	// owner already has a live link to it: that link is returned with Created false.
+	// A taken alias is reported as ErrURLExists.
+	SaveURLIfAbsent(ctx context.Context, urlToSave string, alias string, owner string, expiresAt time.Time) (Saved, error)
+	// SaveURLWithIDAlias is SaveURLIfAbsent with the alias encode(id) of the new row.
+	SaveURLWithIDAlias(ctx context.Context, urlToSave string, owner string, expiresAt time.Time, encode func(id int64) string) (Saved, error)
+	// SaveURLs saves urls in one transaction, each like SaveURLIfAbsent, or like
+	// SaveURLWithIDAlias if its alias is empty and encode is not nil. The result of
+	// urls[i] is at index i; ErrURLExists only fails its own url.
+	SaveURLs(ctx context.Context, urls []NewURL, encode func(id int64) string) ([]SaveResult, error)
+	GetURL(ctx context.Context, alias string) (string, error)
+	GetURLByID(ctx context.Context, id int64) (URL, error)
+	// GetURLByAlias returns the url row with alias, expired or not.
+	GetURLByAlias(ctx context.Context, alias string) (URL, error)
+	GetAliasByURL(ctx context.Context, url string, owner string) (string, error)
+	GetURLOwner(ctx context.Context, alias string) (string, error)
+	// UpdateURL retargets alias. When owner is not empty and the url belongs to
+	// someone else, it fails with ErrNotOwner.
+	UpdateURL(ctx context.Context, alias string, newURL string, owner string) error
+	// ListURLs returns a page of urls and the cursor of the next page, empty on the last one.
+	ListURLs(ctx context.Context, filter ListFilter) ([]URL, string, error)
+	// DeleteURL deletes alias and its clicks. When owner is not empty and the url
+	// belongs to someone else, it fails with ErrNotOwner.
+	DeleteURL(ctx context.Context, alias string, owner string) error
+	// ImportURLs stores urls with their aliases, owners and timestamps in one transaction.
+	// With ConflictFail an existing alias rolls back the whole import with ErrURLExists.
+	ImportURLs(ctx context.Context, urls []URL, onConflict string) (ImportStats, error)
+	// DeleteURLs deletes aliases in one transaction and returns the error of aliases[i]
+	// at index i: ErrUrlNotFound, or ErrNotOwner when owner is not empty and the url
+	// belongs to someone else.
+	DeleteURLs(ctx context.Context, aliases []string, owner string) ([]error, error)
+}
+
+// APIKeyStore keeps hashed api keys; plaintext keys are never stored.
+type APIKeyStore interface {
+	SaveAPIKey(ctx context.Context, name string, keyHash string, scopes []string) (int64, error)
+	// GetAPIKeyByHash returns only keys that have not been revoked.
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+}
+
+// Store is a complete storage backend: urls plus expiration, click analytics and api keys.
+type Store interface {
+	URLStore
+	APIKeyStore
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+	SaveClicks(ctx context.Context, clicks []Click) error
+	GetStats(ctx context.Context, alias string) (Stats, error)
+	Migrator() *migrator.Migrator
+	Ping(ctx context.Context) error
+	// Close releases the database once nothing uses the storage anymore.
+	Close() error
+}
+
+// URL is a saved link as listed by the management api.
+type URL struct {
+	ID        int64      `json:"id"`
+	Alias     string     `json:"alias"`
+	URL       string     `json:"url"`
+	Owner     string     `json:"owner"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// NewURL is a url of a batch save. A zero ExpiresAt means the link never expires.
+type NewURL struct {
+	URL       string
+	Alias     string
+	Owner     string
+	ExpiresAt time.Time
+}
+
+// SaveResult is the outcome of saving one url of a batch.
+type SaveResult struct {
+	Saved
+	Err error
+}
+
+// Saved is the link a save resolved to.
+type Saved struct {
+	ID    int64
+	Alias string
+	// Created is false when an existing link was returned instead of a new one.
+	Created bool
+}
+
 type Request struct {
 	URL   string `json:"url" validate:"required,url"` //validate for validator lib: go-playground/validator/v10
 	Alias string `json:"alias,omitempty"`
+	// optional expiration: either a relative ttl ("24h", "30m") or an absolute timestamp, not both
+	TTL       string     `json:"ttl,omitempty" validate:"omitempty,excluded_with=ExpiresAt"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Expiration resolves the ttl or expires_at of the request into an absolute time.
+// A zero time means the link never expires.
+func (req Request) Expiration(now time.Time) (time.Time, error) {
+	switch {
+	case req.TTL != "":
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid ttl: %q", req.TTL)
+		}
+		if ttl <= 0 {
+			return time.Time{}, errors.New("invalid ttl: must be positive")
+		}
+		return now.Add(ttl), nil
+	case req.ExpiresAt != nil:
+		if !req.ExpiresAt.After(now) {
+			return time.Time{}, errors.New("invalid expires_at: must be in the future")
+		}
+		return *req.ExpiresAt, nil
+	}
+
+	return time.Time{}, nil
+}
+
+// Click is a single resolution of an alias by the redirect handler.
+type Click struct {
+	Alias     string
+	ClickedAt time.Time
+	Referrer  string
+	UserAgent string
+	RequestID string
+	IP        string
+}
+
+type APIKey struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type DailyClicks struct {
+	Date   string `json:"date"` // YYYY-MM-DD, UTC
+	Clicks int64  `json:"clicks"`
+}
+
+type Stats struct {
+	TotalClicks    int64         `json:"total_clicks"`
+	UniqueVisitors int64         `json:"unique_visitors"` // distinct client IPs
+	Daily          []DailyClicks `json:"daily"`
+}
+
+// UpdateRequest retargets an existing alias.
+type UpdateRequest struct {
+	URL string `json:"url" validate:"required,url"`
 }
 
 type Response struct {
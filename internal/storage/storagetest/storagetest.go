@@ -0,0 +1,524 @@
+// Package storagetest holds the behaviour every storage.Store implementation must share.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"url-shortener/internal/lib/random"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run checks store against the storage contract. Aliases are random,
+// so the store may be shared with other tests.
+func Run(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	t.Run("SaveGetDelete", func(t *testing.T) {
+		alias := random.GenerateRandomString(12)
+		url := "https://example.com/" + alias
+
+		id, err := store.SaveURL(ctx, url, alias, "owner", time.Time{})
+		require.NoError(t, err)
+		assert.NotZero(t, id)
+
+		got, err := store.GetURL(ctx, alias)
+		require.NoError(t, err)
+		assert.Equal(t, url, got)
+
+		gotAlias, err := store.GetAliasByURL(ctx, url, "owner")
+		require.NoError(t, err)
+		assert.Equal(t, alias, gotAlias)
+
+		require.NoError(t, store.DeleteURL(ctx, alias, ""))
+
+		_, err = store.GetURL(ctx, alias)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+		assert.ErrorIs(t, store.DeleteURL(ctx, alias, ""), storage.ErrUrlNotFound)
+	})
+
+	t.Run("DuplicateAlias", func(t *testing.T) {
+		alias := random.GenerateRandomString(12)
+
+		_, err := store.SaveURL(ctx, "https://example.com/a", alias, "owner", time.Time{})
+		require.NoError(t, err)
+
+		_, err = store.SaveURL(ctx, "https://example.com/b", alias, "owner", time.Time{})
+		assert.ErrorIs(t, err, storage.ErrURLExists)
+	})
+
+	t.Run("Expiration", func(t *testing.T) {
+		alias := random.GenerateRandomString(12)
+		url := "https://example.com/" + alias
+
+		_, err := store.SaveURL(ctx, url, alias, "owner", time.Now().Add(-time.Second))
+		require.NoError(t, err)
+
+		_, err = store.GetURL(ctx, alias)
+		assert.ErrorIs(t, err, storage.ErrURLExpired)
+
+		u, err := store.GetURLByAlias(ctx, alias)
+		require.NoError(t, err)
+		require.NotNil(t, u.ExpiresAt)
+		assert.True(t, u.ExpiresAt.Before(time.Now()))
+
+		_, err = store.GetAliasByURL(ctx, url, "owner")
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+
+		deleted, err := store.DeleteExpired(ctx, time.Now())
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, deleted, int64(1))
+
+		_, err = store.GetURL(ctx, alias)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		alias := random.GenerateRandomString(12)
+
+		_, err := store.GetStats(ctx, alias)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+
+		_, err = store.SaveURL(ctx, "https://example.com/"+alias, alias, "owner", time.Time{})
+		require.NoError(t, err)
+
+		day1 := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+		day2 := day1.Add(24 * time.Hour)
+		require.NoError(t, store.SaveClicks(ctx, []storage.Click{
+			{Alias: alias, ClickedAt: day1, IP: "10.0.0.1"},
+			{Alias: alias, ClickedAt: day1.Add(time.Hour), IP: "10.0.0.1"},
+			{Alias: alias, ClickedAt: day2, IP: "10.0.0.2", Referrer: "https://ref", UserAgent: "ua", RequestID: "req"},
+		}))
+
+		stats, err := store.GetStats(ctx, alias)
+		require.NoError(t, err)
+		assert.Equal(t, storage.Stats{
+			TotalClicks:    3,
+			UniqueVisitors: 2,
+			Daily: []storage.DailyClicks{
+				{Date: "2025-03-01", Clicks: 2},
+				{Date: "2025-03-02", Clicks: 1},
+			},
+		}, stats)
+
+		require.NoError(t, store.DeleteURL(ctx, alias, ""))
+		_, err = store.SaveURL(ctx, "https://example.com/"+alias, alias, "owner", time.Time{})
+		require.NoError(t, err)
+
+		stats, err = store.GetStats(ctx, alias)
+		require.NoError(t, err)
+		assert.Zero(t, stats.TotalClicks)
+	})
+
+	t.Run("Owner", func(t *testing.T) {
+		owner := "owner-" + random.GenerateRandomString(12)
+		alias := random.GenerateRandomString(12)
+		url := "https://example.com/" + alias
+
+		_, err := store.SaveURL(ctx, url, alias, owner, time.Time{})
+		require.NoError(t, err)
+
+		got, err := store.GetURLOwner(ctx, alias)
+		require.NoError(t, err)
+		assert.Equal(t, owner, got)
+
+		_, err = store.GetURLOwner(ctx, random.GenerateRandomString(12))
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+
+		_, err = store.GetAliasByURL(ctx, url, "someone-else")
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+
+		assert.ErrorIs(t, store.UpdateURL(ctx, alias, "https://example.com/other", "someone-else"), storage.ErrNotOwner)
+		assert.ErrorIs(t, store.DeleteURL(ctx, alias, "someone-else"), storage.ErrNotOwner)
+		got, err = store.GetURL(ctx, alias)
+		require.NoError(t, err)
+		assert.Equal(t, url, got)
+
+		urls, next, err := store.ListURLs(ctx, storage.ListFilter{Owner: owner, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, urls, 1)
+		assert.Empty(t, next)
+		assert.Equal(t, alias, urls[0].Alias)
+		assert.Equal(t, url, urls[0].URL)
+		assert.Equal(t, owner, urls[0].Owner)
+		assert.WithinDuration(t, time.Now(), urls[0].CreatedAt, time.Minute)
+
+		all, _, err := store.ListURLs(ctx, storage.ListFilter{Limit: 10})
+		require.NoError(t, err)
+		assert.Greater(t, len(all), 1)
+	})
+
+	t.Run("UpdateURL", func(t *testing.T) {
+		alias := random.GenerateRandomString(12)
+
+		_, err := store.SaveURL(ctx, "https://old.example.com/"+alias, alias, "owner", time.Time{})
+		require.NoError(t, err)
+		require.NoError(t, store.SaveClicks(ctx, []storage.Click{{Alias: alias, ClickedAt: time.Now(), IP: "10.0.0.1"}}))
+
+		require.NoError(t, store.UpdateURL(ctx, alias, "https://new.example.org/"+alias, "owner"))
+
+		got, err := store.GetURL(ctx, alias)
+		require.NoError(t, err)
+		assert.Equal(t, "https://new.example.org/"+alias, got)
+
+		owner, err := store.GetURLOwner(ctx, alias)
+		require.NoError(t, err)
+		assert.Equal(t, "owner", owner)
+
+		stats, err := store.GetStats(ctx, alias)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), stats.TotalClicks)
+
+		urls, _, err := store.ListURLs(ctx, storage.ListFilter{Domain: "new.example.org", AliasPrefix: alias, Limit: 1})
+		require.NoError(t, err)
+		assert.Len(t, urls, 1)
+
+		assert.ErrorIs(t, store.UpdateURL(ctx, random.GenerateRandomString(12), "https://example.com", ""), storage.ErrUrlNotFound)
+	})
+
+	t.Run("SaveIfAbsent", func(t *testing.T) {
+		alias := random.GenerateRandomString(12)
+		url := "https://example.com/" + alias
+
+		saved, err := store.SaveURLIfAbsent(ctx, url, alias, "owner", time.Time{})
+		require.NoError(t, err)
+		assert.True(t, saved.Created)
+		assert.Equal(t, alias, saved.Alias)
+
+		again, err := store.SaveURLIfAbsent(ctx, url, random.GenerateRandomString(12), "owner", time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, storage.Saved{ID: saved.ID, Alias: alias}, again)
+
+		//other owners and expiring links get their own alias
+		other, err := store.SaveURLIfAbsent(ctx, url, random.GenerateRandomString(12), "other", time.Time{})
+		require.NoError(t, err)
+		assert.True(t, other.Created)
+		expiring, err := store.SaveURLIfAbsent(ctx, url, random.GenerateRandomString(12), "owner", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.True(t, expiring.Created)
+
+		_, err = store.SaveURLIfAbsent(ctx, "https://example.com/other", alias, "owner", time.Time{})
+		assert.ErrorIs(t, err, storage.ErrURLExists)
+	})
+
+	t.Run("ConcurrentSaves", func(t *testing.T) {
+		const workers = 200
+
+		url := "https://example.com/" + random.GenerateRandomString(12)
+		alias := random.GenerateRandomString(12)
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			aliases = map[string]int{}
+			created int
+			taken   int
+		)
+		for i := 0; i < workers; i++ {
+			wg.Add(2)
+			//the same url under distinct aliases resolves to a single link
+			go func() {
+				defer wg.Done()
+				saved, err := store.SaveURLIfAbsent(ctx, url, random.GenerateRandomString(12), "owner", time.Time{})
+				assert.NoError(t, err)
+
+				mu.Lock()
+				defer mu.Unlock()
+				aliases[saved.Alias]++
+				if saved.Created {
+					created++
+				}
+			}()
+			//distinct urls under the same alias: exactly one wins
+			go func() {
+				defer wg.Done()
+				_, err := store.SaveURLIfAbsent(ctx, url+"/"+random.GenerateRandomString(12), alias, "owner", time.Time{})
+				if errors.Is(err, storage.ErrURLExists) {
+					mu.Lock()
+					taken++
+					mu.Unlock()
+					return
+				}
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, aliases, 1)
+		assert.Equal(t, 1, created)
+		assert.Equal(t, workers-1, taken)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		prefix := random.GenerateRandomString(12)
+		taken := prefix + "-taken"
+		_, err := store.SaveURL(ctx, "https://example.com/taken", taken, "other", time.Time{})
+		require.NoError(t, err)
+
+		results, err := store.SaveURLs(ctx, []storage.NewURL{
+			{URL: "https://example.com/" + prefix, Alias: prefix + "-a", Owner: "owner"},
+			{URL: "https://example.com/" + prefix, Alias: prefix + "-b", Owner: "owner"},
+			{URL: "https://example.com/x", Alias: taken, Owner: "owner"},
+			{URL: "https://example.com/id/" + prefix, Owner: "owner"},
+		}, func(id int64) string { return prefix + strconv.FormatInt(id, 10) })
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+
+		assert.NoError(t, results[0].Err)
+		assert.True(t, results[0].Created)
+		assert.Equal(t, storage.Saved{ID: results[0].ID, Alias: prefix + "-a"}, results[1].Saved)
+		assert.ErrorIs(t, results[2].Err, storage.ErrURLExists)
+		assert.NoError(t, results[3].Err)
+		assert.Equal(t, prefix+strconv.FormatInt(results[3].ID, 10), results[3].Alias)
+
+		errs, err := store.DeleteURLs(ctx, []string{prefix + "-a", prefix + "-missing", taken}, "owner")
+		require.NoError(t, err)
+		require.Len(t, errs, 3)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], storage.ErrUrlNotFound)
+		assert.ErrorIs(t, errs[2], storage.ErrNotOwner)
+
+		_, err = store.GetURL(ctx, prefix+"-a")
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+
+		//an empty owner deletes links of anyone
+		errs, err = store.DeleteURLs(ctx, []string{taken, results[3].Alias}, "")
+		require.NoError(t, err)
+		assert.Equal(t, []error{nil, nil}, errs)
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		prefix := random.GenerateRandomString(12)
+		createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+		_, err := store.SaveURL(ctx, "https://example.com/existing", prefix+"-existing", "owner", time.Time{})
+		require.NoError(t, err)
+
+		urls := []storage.URL{
+			{Alias: prefix + "-a", URL: "https://example.com/a", Owner: "imported", CreatedAt: createdAt, ExpiresAt: &expiresAt},
+			{Alias: prefix + "-existing", URL: "https://example.com/new", Owner: "imported"},
+		}
+
+		_, err = store.ImportURLs(ctx, urls, storage.ConflictFail)
+		assert.ErrorIs(t, err, storage.ErrURLExists)
+		_, err = store.GetURL(ctx, prefix+"-a")
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound, "failed import must roll back")
+
+		stats, err := store.ImportURLs(ctx, urls, storage.ConflictSkip)
+		require.NoError(t, err)
+		assert.Equal(t, storage.ImportStats{Created: 1, Skipped: 1}, stats)
+
+		got, _, err := store.ListURLs(ctx, storage.ListFilter{AliasPrefix: prefix + "-a", Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, "imported", got[0].Owner)
+		assert.True(t, createdAt.Equal(got[0].CreatedAt))
+		require.NotNil(t, got[0].ExpiresAt)
+		assert.True(t, expiresAt.Equal(*got[0].ExpiresAt))
+
+		existing, err := store.GetURL(ctx, prefix+"-existing")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/existing", existing)
+
+		stats, err = store.ImportURLs(ctx, urls, storage.ConflictOverwrite)
+		require.NoError(t, err)
+		assert.Equal(t, storage.ImportStats{Overwritten: 2}, stats)
+
+		existing, err = store.GetURL(ctx, prefix+"-existing")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/new", existing)
+		owner, err := store.GetURLOwner(ctx, prefix+"-existing")
+		require.NoError(t, err)
+		assert.Equal(t, "imported", owner)
+	})
+
+	t.Run("IDAlias", func(t *testing.T) {
+		prefix := random.GenerateRandomString(12)
+		encode := func(id int64) string { return prefix + strconv.FormatInt(id, 10) }
+
+		saved, err := store.SaveURLWithIDAlias(ctx, "https://example.com/"+prefix, "owner", time.Time{}, encode)
+		require.NoError(t, err)
+		assert.True(t, saved.Created)
+		id, alias := saved.ID, saved.Alias
+		assert.Equal(t, encode(id), alias)
+
+		again, err := store.SaveURLWithIDAlias(ctx, "https://example.com/"+prefix, "owner", time.Time{}, encode)
+		require.NoError(t, err)
+		assert.Equal(t, storage.Saved{ID: id, Alias: alias}, again)
+
+		u, err := store.GetURLByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, alias, u.Alias)
+		assert.Equal(t, "https://example.com/"+prefix, u.URL)
+		assert.Equal(t, "owner", u.Owner)
+
+		got, err := store.GetURL(ctx, alias)
+		require.NoError(t, err)
+		assert.Equal(t, u.URL, got)
+
+		byAlias, err := store.GetURLByAlias(ctx, alias)
+		require.NoError(t, err)
+		assert.Equal(t, u, byAlias)
+
+		require.NoError(t, store.DeleteURL(ctx, alias, ""))
+		_, err = store.GetURLByID(ctx, id)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+		_, err = store.GetURLByAlias(ctx, alias)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	})
+
+	t.Run("IDAliasNotReused", func(t *testing.T) {
+		prefix := random.GenerateRandomString(12)
+		encode := func(id int64) string { return prefix + strconv.FormatInt(id, 10) }
+
+		newest, err := store.SaveURLWithIDAlias(ctx, "https://example.com/"+prefix+"/a", "owner", time.Time{}, encode)
+		require.NoError(t, err)
+		require.NoError(t, store.DeleteURL(ctx, newest.Alias, ""))
+
+		saved, err := store.SaveURLWithIDAlias(ctx, "https://example.com/"+prefix+"/b", "owner", time.Time{}, encode)
+		require.NoError(t, err)
+		assert.Greater(t, saved.ID, newest.ID)
+		assert.NotEqual(t, newest.Alias, saved.Alias)
+
+		_, err = store.GetURL(ctx, newest.Alias)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	})
+
+	t.Run("IDAliasTaken", func(t *testing.T) {
+		prefix := random.GenerateRandomString(12)
+		encode := func(id int64) string { return prefix + strconv.FormatInt(id, 10) }
+
+		first, err := store.SaveURLWithIDAlias(ctx, "https://example.com/"+prefix+"/a", "owner", time.Time{}, encode)
+		require.NoError(t, err)
+		//the three custom links take the next ids, and the aliases of the three after them
+		for id := first.ID + 4; id <= first.ID+6; id++ {
+			_, err := store.SaveURL(ctx, "https://example.com/custom", encode(id), "owner", time.Time{})
+			require.NoError(t, err)
+		}
+
+		saved, err := store.SaveURLWithIDAlias(ctx, "https://example.com/"+prefix+"/b", "owner", time.Time{}, encode)
+		require.NoError(t, err)
+		assert.Equal(t, first.ID+7, saved.ID)
+		assert.Equal(t, encode(saved.ID), saved.Alias)
+		got, err := store.GetURL(ctx, saved.Alias)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/"+prefix+"/b", got)
+	})
+
+	t.Run("ListFilters", func(t *testing.T) {
+		owner := "owner-" + random.GenerateRandomString(12)
+		prefix := random.GenerateRandomString(6)
+
+		saved := map[string]string{
+			prefix + "-a": "https://example.com/docs",
+			prefix + "-b": "https://blog.example.com/post",
+			prefix + "-c": "https://other.org/example_page",
+			"x" + prefix:  "https://other.org/x",
+		}
+		for alias, url := range saved {
+			_, err := store.SaveURL(ctx, url, alias, owner, time.Time{})
+			require.NoError(t, err)
+		}
+
+		aliases := func(filter storage.ListFilter) []string {
+			filter.Owner = owner
+			filter.Sort = storage.SortAlias
+			filter.Limit = 10
+			urls, _, err := store.ListURLs(ctx, filter)
+			require.NoError(t, err)
+			var res []string
+			for _, u := range urls {
+				res = append(res, u.Alias)
+			}
+			return res
+		}
+
+		assert.Equal(t, []string{prefix + "-a", prefix + "-b"}, aliases(storage.ListFilter{Domain: "example.com"}))
+		assert.Equal(t, []string{prefix + "-b"}, aliases(storage.ListFilter{Domain: "blog.example.com"}))
+		assert.Equal(t, []string{prefix + "-c"}, aliases(storage.ListFilter{URLContains: "example_"}))
+		assert.Equal(t, []string{prefix + "-a", prefix + "-b", prefix + "-c"}, aliases(storage.ListFilter{AliasPrefix: prefix}))
+		assert.Empty(t, aliases(storage.ListFilter{CreatedTo: time.Now().Add(-time.Hour)}))
+		assert.Len(t, aliases(storage.ListFilter{CreatedFrom: time.Now().Add(-time.Hour)}), 4)
+	})
+
+	t.Run("ListPagination", func(t *testing.T) {
+		owner := "owner-" + random.GenerateRandomString(12)
+
+		var want []string
+		for i := 0; i < 7; i++ {
+			alias := random.GenerateRandomString(12)
+			_, err := store.SaveURL(ctx, "https://example.com/"+alias, alias, owner, time.Time{})
+			require.NoError(t, err)
+			want = append(want, alias)
+		}
+
+		for _, sort := range []string{storage.SortCreatedAt, storage.SortAlias} {
+			for _, desc := range []bool{false, true} {
+				var got []string
+				cursor := ""
+				pages := 0
+				for {
+					urls, next, err := store.ListURLs(ctx, storage.ListFilter{
+						Owner: owner, Sort: sort, Desc: desc, Limit: 3, Cursor: cursor,
+					})
+					require.NoError(t, err)
+					pages++
+					for _, u := range urls {
+						got = append(got, u.Alias)
+					}
+					if next == "" {
+						break
+					}
+					cursor = next
+				}
+
+				assert.Equal(t, 3, pages)
+				assert.ElementsMatch(t, want, got)
+				if sort == storage.SortAlias {
+					assert.True(t, slices.IsSorted(got) != desc || len(got) < 2, "sort %s desc=%v: %v", sort, desc, got)
+				} else if !desc {
+					assert.Equal(t, want, got)
+				}
+			}
+		}
+
+		_, _, err := store.ListURLs(ctx, storage.ListFilter{Owner: owner, Limit: 3, Cursor: "not a cursor"})
+		assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	})
+
+	t.Run("APIKeys", func(t *testing.T) {
+		name := "key-" + random.GenerateRandomString(12)
+		hash := random.GenerateRandomString(64)
+
+		id, err := store.SaveAPIKey(ctx, name, hash, []string{"create", "read-stats"})
+		require.NoError(t, err)
+
+		_, err = store.SaveAPIKey(ctx, name, random.GenerateRandomString(64), []string{"create"})
+		assert.ErrorIs(t, err, storage.ErrAPIKeyExists)
+
+		key, err := store.GetAPIKeyByHash(ctx, hash)
+		require.NoError(t, err)
+		assert.Equal(t, id, key.ID)
+		assert.Equal(t, name, key.Name)
+		assert.Equal(t, []string{"create", "read-stats"}, key.Scopes)
+		assert.Nil(t, key.RevokedAt)
+
+		keys, err := store.ListAPIKeys(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, keys, key)
+
+		require.NoError(t, store.RevokeAPIKey(ctx, id))
+		assert.ErrorIs(t, store.RevokeAPIKey(ctx, id), storage.ErrAPIKeyNotFound)
+
+		_, err = store.GetAPIKeyByHash(ctx, hash)
+		assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+	})
+}
@@ -0,0 +1,306 @@
+// Package client is a Go client of the url-shortener HTTP api, as described by
+// the OpenAPI document the service serves at /openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Error codes of the api. Branch on APIError.Code rather than on the message.
+const (
+	CodeInvalidRequest    = "invalid_request"
+	CodeValidationFailed  = "validation_failed"
+	CodeInvalidURL        = "invalid_url"
+	CodeInvalidAlias      = "invalid_alias"
+	CodeInvalidExpiration = "invalid_expiration"
+	CodeAliasTaken        = "alias_taken"
+	CodeNameTaken         = "name_taken"
+	CodeNotFound          = "not_found"
+	CodeExpired           = "expired"
+	CodeUnauthorized      = "unauthorized"
+	CodeForbidden         = "forbidden"
+	CodeTooLarge          = "too_large"
+	CodeNotReady          = "not_ready"
+	CodeTimeout           = "timeout"
+	CodeInternal          = "internal_error"
+)
+
+// APIError is an error response of the api.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Fields     []FieldError
+}
+
+// FieldError is an invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("url-shortener: %d %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("url-shortener: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsCode reports whether err is an *APIError with the given code.
+func IsCode(err error, code string) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	apiKey     string
+	user       string
+	password   string
+}
+
+type Option func(*Client)
+
+// WithAPIKey authenticates requests with an api key created by POST /admin/keys.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithBasicAuth authenticates requests as the admin user of the service.
+func WithBasicAuth(user, password string) Option {
+	return func(c *Client) {
+		c.user = user
+		c.password = password
+	}
+}
+
+// WithHTTPClient replaces http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New returns a client of the service at baseURL, e.g. "https://sho.rt".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// ShortenRequest is a url to save. At most one of TTL and ExpiresAt may be set;
+// without either the link never expires.
+type ShortenRequest struct {
+	URL string
+	// Alias is generated by the service if empty.
+	Alias     string
+	TTL       time.Duration
+	ExpiresAt time.Time
+}
+
+type shortenBody struct {
+	URL       string     `json:"url"`
+	Alias     string     `json:"alias,omitempty"`
+	TTL       string     `json:"ttl,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type aliasResponse struct {
+	Alias string `json:"alias"`
+}
+
+// Shorten saves a url and returns its alias. A taken req.Alias fails with an
+// APIError of code CodeAliasTaken. The alias may still differ from req.Alias:
+// saving a url again without expiry returns the alias it already has.
+func (c *Client) Shorten(ctx context.Context, req ShortenRequest) (string, error) {
+	const op = "client.Shorten"
+
+	body := shortenBody{URL: req.URL, Alias: req.Alias}
+	if req.TTL != 0 {
+		body.TTL = req.TTL.String()
+	}
+	if !req.ExpiresAt.IsZero() {
+		body.ExpiresAt = &req.ExpiresAt
+	}
+
+	var resp aliasResponse
+	if err := c.do(ctx, http.MethodPost, "/url", body, &resp); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return resp.Alias, nil
+}
+
+// Resolve returns the url an alias redirects to.
+func (c *Client) Resolve(ctx context.Context, alias string) (string, error) {
+	const op = "client.Resolve"
+
+	req, err := c.newRequest(ctx, http.MethodGet, "/"+url.PathEscape(alias), nil)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	httpClient := *c.httpClient
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		return "", fmt.Errorf("%s: %w", op, decodeError(resp))
+	}
+
+	return resp.Header.Get("Location"), nil
+}
+
+// Update points an existing alias to another url.
+func (c *Client) Update(ctx context.Context, alias string, newURL string) error {
+	const op = "client.Update"
+
+	body := struct {
+		URL string `json:"url"`
+	}{URL: newURL}
+
+	if err := c.do(ctx, http.MethodPatch, "/url/"+url.PathEscape(alias), body, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Delete deletes a link.
+func (c *Client) Delete(ctx context.Context, alias string) error {
+	const op = "client.Delete"
+
+	if err := c.do(ctx, http.MethodDelete, "/url/"+url.PathEscape(alias), nil, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+type DailyClicks struct {
+	Date   string `json:"date"` // YYYY-MM-DD, UTC
+	Clicks int64  `json:"clicks"`
+}
+
+type Stats struct {
+	Alias          string        `json:"alias"`
+	TotalClicks    int64         `json:"total_clicks"`
+	UniqueVisitors int64         `json:"unique_visitors"`
+	Daily          []DailyClicks `json:"daily"`
+}
+
+// Stats returns the click statistics of a link.
+func (c *Client) Stats(ctx context.Context, alias string) (Stats, error) {
+	const op = "client.Stats"
+
+	var stats Stats
+	if err := c.do(ctx, http.MethodGet, "/url/"+url.PathEscape(alias)+"/stats", nil, &stats); err != nil {
+		return Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	switch {
+	case c.apiKey != "":
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	case c.user != "":
+		req.SetBasicAuth(c.user, c.password)
+	}
+
+	return req, nil
+}
+
+// do sends a json request and decodes a successful response into out, if not nil.
+func (c *Client) do(ctx context.Context, method, path string, body any, out any) error {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+func decodeError(resp *http.Response) error {
+	var body struct {
+		Code   string       `json:"code"`
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields"`
+	}
+	// the body may be missing or not json, e.g. from a proxy in front of the service
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(data, &body); err != nil || body.Error == "" {
+		body.Error = strings.TrimSpace(string(data))
+		if body.Error == "" {
+			body.Error = http.StatusText(resp.StatusCode)
+		}
+	}
+
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Code:       body.Code,
+		Message:    body.Error,
+		Fields:     body.Fields,
+	}
+}
@@ -0,0 +1,229 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/handlers/openapi"
+	"url-shortener/pkg/client"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer(t *testing.T, handler http.HandlerFunc) *client.Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return client.New(srv.URL+"/", client.WithAPIKey("key"))
+}
+
+func TestShorten(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/url", r.URL.Path)
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"url": "https://example.com", "alias": "ex", "expires_at": "2030-01-02T03:04:05Z"}`, string(body))
+
+		_, _ = w.Write([]byte(`{"status": "OK", "alias": "ex"}`))
+	})
+
+	alias, err := c.Shorten(context.Background(), client.ShortenRequest{URL: "https://example.com", Alias: "ex", ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	assert.Equal(t, "ex", alias)
+}
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   client.APIError
+	}{
+		{
+			name:   "api error",
+			status: http.StatusUnprocessableEntity,
+			body:   `{"status": "Error", "code": "validation_failed", "error": "invalid url", "fields": [{"field": "url", "rule": "url", "message": "must be a url"}]}`,
+			want: client.APIError{
+				StatusCode: http.StatusUnprocessableEntity,
+				Code:       client.CodeValidationFailed,
+				Message:    "invalid url",
+				Fields:     []client.FieldError{{Field: "url", Rule: "url", Message: "must be a url"}},
+			},
+		},
+		{
+			name:   "text from a proxy",
+			status: http.StatusBadGateway,
+			body:   "upstream unavailable\n",
+			want:   client.APIError{StatusCode: http.StatusBadGateway, Message: "upstream unavailable"},
+		},
+		{
+			name:   "json without error",
+			status: http.StatusServiceUnavailable,
+			body:   `{"message": "maintenance"}`,
+			want:   client.APIError{StatusCode: http.StatusServiceUnavailable, Message: `{"message": "maintenance"}`},
+		},
+		{
+			name:   "empty body",
+			status: http.StatusInternalServerError,
+			want:   client.APIError{StatusCode: http.StatusInternalServerError, Message: "Internal Server Error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			})
+
+			err := c.Delete(context.Background(), "ex")
+			var apiErr *client.APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, tt.want, *apiErr)
+			assert.Contains(t, err.Error(), fmt.Sprint(tt.status))
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/found":
+			http.Redirect(w, r, "https://example.com/target", http.StatusFound)
+		case "/moved":
+			//only a 302 is a resolution, other redirects are not followed either
+			http.Redirect(w, r, "/found", http.StatusMovedPermanently)
+		case "/ok":
+			_, _ = w.Write([]byte("<html></html>"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"status": "Error", "code": "not_found", "error": "url not found"}`))
+		}
+	})
+	ctx := context.Background()
+
+	got, err := c.Resolve(ctx, "found")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/target", got)
+
+	_, err = c.Resolve(ctx, "moved")
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusMovedPermanently, apiErr.StatusCode)
+
+	_, err = c.Resolve(ctx, "ok")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusOK, apiErr.StatusCode)
+	assert.Empty(t, apiErr.Code)
+
+	_, err = c.Resolve(ctx, "missing")
+	assert.True(t, client.IsCode(err, client.CodeNotFound))
+}
+
+func TestIsCode(t *testing.T) {
+	err := fmt.Errorf("client.Delete: %w", &client.APIError{StatusCode: http.StatusForbidden, Code: client.CodeForbidden})
+
+	assert.True(t, client.IsCode(err, client.CodeForbidden))
+	assert.False(t, client.IsCode(err, client.CodeNotFound))
+	assert.False(t, client.IsCode(errors.New("forbidden"), client.CodeForbidden))
+	assert.False(t, client.IsCode(nil, ""))
+}
+
+// TestCodesMatchSpec fails when the error codes of the client and the spec drift apart.
+func TestCodesMatchSpec(t *testing.T) {
+	var spec struct {
+		Components struct {
+			Schemas struct {
+				Response struct {
+					Properties struct {
+						Code struct {
+							Enum []string `json:"enum"`
+						} `json:"code"`
+					} `json:"properties"`
+				} `json:"Response"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(openapi.Spec, &spec))
+
+	codes := []string{
+		client.CodeInvalidRequest,
+		client.CodeValidationFailed,
+		client.CodeInvalidURL,
+		client.CodeInvalidAlias,
+		client.CodeInvalidExpiration,
+		client.CodeAliasTaken,
+		client.CodeNameTaken,
+		client.CodeNotFound,
+		client.CodeExpired,
+		client.CodeUnauthorized,
+		client.CodeForbidden,
+		client.CodeTooLarge,
+		client.CodeNotReady,
+		client.CodeTimeout,
+		client.CodeInternal,
+	}
+	assert.ElementsMatch(t, spec.Components.Schemas.Response.Properties.Code.Enum, codes)
+}
+
+// TestRequestsMatchSpec fails when the client sends a request the spec does not describe.
+func TestRequestsMatchSpec(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(openapi.Spec, &spec))
+
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent = append(sent, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		if r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/url/") {
+			http.Redirect(w, r, "https://example.com", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status": "OK"}`))
+	})
+
+	ctx := context.Background()
+	_, err := c.Shorten(ctx, client.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	_, err = c.Resolve(ctx, "ex")
+	require.NoError(t, err)
+	require.NoError(t, c.Update(ctx, "ex", "https://example.org"))
+	_, err = c.Stats(ctx, "ex")
+	require.NoError(t, err)
+	require.NoError(t, c.Delete(ctx, "ex"))
+
+	//path parameters, as quoted by regexp.QuoteMeta
+	param := regexp.MustCompile(`\\\{[^/]+\\\}`)
+	for _, req := range sent {
+		method, path, _ := strings.Cut(req, " ")
+		described := false
+		for route, ops := range spec.Paths {
+			pattern := "^" + param.ReplaceAllString(regexp.QuoteMeta(route), "[^/]+") + "$"
+			if _, ok := ops[strings.ToLower(method)]; ok && regexp.MustCompile(pattern).MatchString(path) {
+				described = true
+				break
+			}
+		}
+		assert.True(t, described, "%s is not in the spec", req)
+	}
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
@@ -14,6 +15,7 @@ import (
 
 	"url-shortener/internal/lib/random"
 	"url-shortener/internal/storage"
+	"url-shortener/pkg/client"
 
 	gofakeit "github.com/brianvoe/gofakeit/v6"
 	he "github.com/gavv/httpexpect/v2"
@@ -29,7 +31,7 @@ func TestMain(m *testing.M) {
 	}
 	defer os.RemoveAll(tempDir)
 
-	mainPath, _ := filepath.Abs("../cmd/url-shortener/main.go")
+	mainPath, _ := filepath.Abs("../cmd/url-shortener")
 
 	binName := "server"
 	if runtime.GOOS == "windows" {
@@ -78,7 +80,7 @@ http_server:
 		os.Exit(1)
 	}
 
-	if err := waitForServer(baseAddr); err != nil {
+	if err := waitForServer(baseAddr + "/readyz"); err != nil {
 		fmt.Printf("Server failed to start: %v\n", err)
 		cmd.Process.Kill()
 		os.Exit(1)
@@ -86,7 +88,12 @@ http_server:
 
 	exitCode := m.Run()
 
-	killServer(cmd)
+	if err := stopServer(cmd); err != nil {
+		fmt.Printf("Server did not shut down cleanly: %v\n", err)
+		if exitCode == 0 {
+			exitCode = 1
+		}
+	}
 
 	os.Exit(exitCode)
 }
@@ -109,21 +116,38 @@ func waitForServer(url string) error {
 		resp, err := http.Get(url)
 		if err == nil {
 			resp.Body.Close()
-			return nil
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
 		}
 		time.Sleep(100 * time.Millisecond)
 	}
 	return fmt.Errorf("timeout")
 }
 
-func killServer(cmd *exec.Cmd) {
+// stopServer sends SIGTERM and waits for the server to drain and exit with status 0.
+func stopServer(cmd *exec.Cmd) error {
 	if cmd.Process == nil {
-		return
+		return nil
 	}
 	if runtime.GOOS == "windows" {
 		cmd.Process.Kill()
-	} else {
-		cmd.Process.Signal(syscall.SIGTERM)
+		cmd.Wait()
+		return nil
+	}
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(15 * time.Second):
+		cmd.Process.Kill()
+		return fmt.Errorf("timeout")
 	}
 }
 
@@ -155,6 +179,8 @@ func TestURLShortener_SaveRedirectDelete(t *testing.T) {
 		url         string
 		alias       string
 		error       string
+		status      int
+		code        string
 		checkErrStr bool
 	}{
 		{
@@ -168,6 +194,8 @@ func TestURLShortener_SaveRedirectDelete(t *testing.T) {
 			alias:       gofakeit.Word(),
 			checkErrStr: false,
 			error:       "validation failed",
+			status:      http.StatusUnprocessableEntity,
+			code:        "invalid_url",
 		},
 		{
 			name:  "Empty Alias",
@@ -188,18 +216,25 @@ func TestURLShortener_SaveRedirectDelete(t *testing.T) {
 				},
 			})
 
+			status := tc.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
 			resp := e.POST("/url").
 				WithJSON(storage.Request{
 					URL:   tc.url,
 					Alias: tc.alias,
 				}).
 				WithBasicAuth("admin", "password123").
-				Expect().Status(http.StatusOK).
+				Expect().Status(status).
 				JSON().Object()
 
 			if tc.error != "" {
 				resp.NotContainsKey("alias")
 				resp.Value("error").String().NotEmpty()
+				resp.Value("code").String().IsEqual(tc.code)
+				resp.Value("fields").Array().Value(0).Object().Value("field").String().IsEqual("url")
 				return
 			}
 
@@ -213,7 +248,7 @@ func TestURLShortener_SaveRedirectDelete(t *testing.T) {
 
 			testRedirect(e, alias, tc.url)
 
-			e.DELETE("/"+alias).
+			e.DELETE("/url/"+alias).
 				WithBasicAuth("admin", "password123").
 				Expect().Status(http.StatusOK).
 				JSON().Object().
@@ -224,17 +259,378 @@ func TestURLShortener_SaveRedirectDelete(t *testing.T) {
 	}
 }
 
+// redirects are public, so no credentials are sent
 func testRedirect(e *he.Expect, alias string, urlToRedirect string) {
-	e.GET("/"+alias).
-		WithBasicAuth("admin", "password123").
+	e.GET("/" + alias).
 		Expect().
 		Status(http.StatusFound).
 		Header("Location").IsEqual(urlToRedirect)
 }
 
 func testRedirectNotFound(e *he.Expect, alias string) {
-	e.GET("/"+alias).
-		WithBasicAuth("admin", "password123").
+	e.GET("/" + alias).
 		Expect().
 		Status(http.StatusNotFound)
 }
+
+func TestURLShortener_Expiration(t *testing.T) {
+	e := he.WithConfig(he.Config{
+		BaseURL:  baseAddr,
+		Reporter: he.NewAssertReporter(t),
+		Client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	})
+
+	url := gofakeit.URL()
+	alias := random.GenerateRandomString(10)
+
+	e.POST("/url").
+		WithJSON(storage.Request{
+			URL:   url,
+			Alias: alias,
+			TTL:   "1s",
+		}).
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusOK).
+		JSON().Object().
+		Value("alias").String().IsEqual(alias)
+
+	testRedirect(e, alias, url)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	e.GET("/" + alias).
+		Expect().
+		Status(http.StatusGone)
+}
+
+func TestURLShortener_Stats(t *testing.T) {
+	e := he.WithConfig(he.Config{
+		BaseURL:  baseAddr,
+		Reporter: he.NewAssertReporter(t),
+		Client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	})
+
+	url := gofakeit.URL()
+	alias := random.GenerateRandomString(10)
+
+	e.POST("/url").
+		WithJSON(storage.Request{
+			URL:   url,
+			Alias: alias,
+		}).
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusOK)
+
+	for i := 0; i < 3; i++ {
+		testRedirect(e, alias, url)
+	}
+
+	// clicks are written asynchronously
+	time.Sleep(1500 * time.Millisecond)
+
+	stats := e.GET("/url/"+alias+"/stats").
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusOK).
+		JSON().Object()
+
+	stats.Value("total_clicks").Number().IsEqual(3)
+	stats.Value("unique_visitors").Number().IsEqual(1)
+	stats.Value("daily").Array().Length().IsEqual(1)
+	stats.Value("daily").Array().Value(0).Object().Value("clicks").Number().IsEqual(3)
+
+	e.GET("/url/unknown_alias_"+alias+"/stats").
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusNotFound)
+}
+
+func TestURLShortener_APIKeys(t *testing.T) {
+	e := he.Default(t, baseAddr)
+
+	key := e.POST("/admin/keys").
+		WithJSON(map[string]any{
+			"name":   "ci-" + random.GenerateRandomString(8),
+			"scopes": []string{"create"},
+		}).
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusCreated).
+		JSON().Object()
+
+	token := key.Value("key").String().NotEmpty().Raw()
+	id := int64(key.Value("id").Number().Raw())
+
+	alias := e.POST("/url").
+		WithJSON(storage.Request{URL: gofakeit.URL()}).
+		WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusOK).
+		JSON().Object().
+		Value("alias").String().NotEmpty().Raw()
+
+	// the key has no delete scope
+	e.DELETE("/url/"+alias).
+		WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusForbidden)
+
+	// api keys cannot manage other api keys without the admin scope
+	e.GET("/admin/keys").
+		WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusForbidden)
+
+	e.DELETE(fmt.Sprintf("/admin/keys/%d", id)).
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusOK)
+
+	e.POST("/url").
+		WithJSON(storage.Request{URL: gofakeit.URL()}).
+		WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusUnauthorized)
+}
+
+func TestURLShortener_ManagementRequiresAuth(t *testing.T) {
+	e := he.Default(t, baseAddr)
+
+	e.POST("/url").
+		WithJSON(storage.Request{URL: gofakeit.URL()}).
+		Expect().Status(http.StatusUnauthorized)
+
+	e.DELETE("/url/" + random.GenerateRandomString(10)).
+		Expect().Status(http.StatusUnauthorized)
+
+	e.GET("/admin/keys").
+		Expect().Status(http.StatusUnauthorized)
+}
+
+func TestURLShortener_Ownership(t *testing.T) {
+	e := he.Default(t, baseAddr)
+
+	newKey := func(name string) string {
+		return e.POST("/admin/keys").
+			WithJSON(map[string]any{
+				"name":   name,
+				"scopes": []string{"create", "delete"},
+			}).
+			WithBasicAuth("admin", "password123").
+			Expect().Status(http.StatusCreated).
+			JSON().Object().
+			Value("key").String().Raw()
+	}
+	alice := newKey("alice-" + random.GenerateRandomString(8))
+	bob := newKey("bob-" + random.GenerateRandomString(8))
+
+	alias := random.GenerateRandomString(10)
+	e.POST("/url").
+		WithJSON(storage.Request{URL: gofakeit.URL(), Alias: alias}).
+		WithHeader("Authorization", "Bearer "+alice).
+		Expect().Status(http.StatusOK)
+
+	e.GET("/url").WithQuery("owner", "me").
+		WithHeader("Authorization", "Bearer "+alice).
+		Expect().Status(http.StatusOK).
+		JSON().Object().
+		Value("urls").Array().Length().IsEqual(1)
+
+	e.GET("/url").WithQuery("owner", "me").
+		WithHeader("Authorization", "Bearer "+bob).
+		Expect().Status(http.StatusOK).
+		JSON().Object().
+		Value("urls").Array().IsEmpty()
+
+	e.DELETE("/url/"+alias).
+		WithHeader("Authorization", "Bearer "+bob).
+		Expect().Status(http.StatusForbidden)
+
+	e.DELETE("/url/"+alias).
+		WithHeader("Authorization", "Bearer "+alice).
+		Expect().Status(http.StatusOK)
+}
+
+func TestURLShortener_Update(t *testing.T) {
+	e := he.WithConfig(he.Config{
+		BaseURL:  baseAddr,
+		Reporter: he.NewAssertReporter(t),
+		Client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	})
+
+	alias := random.GenerateRandomString(10)
+	oldURL, newURL := gofakeit.URL(), gofakeit.URL()
+
+	e.POST("/url").
+		WithJSON(storage.Request{URL: oldURL, Alias: alias}).
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusOK)
+
+	testRedirect(e, alias, oldURL)
+
+	e.PATCH("/url/"+alias).
+		WithJSON(storage.UpdateRequest{URL: newURL}).
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusOK).
+		JSON().Object().
+		Value("alias").String().IsEqual(alias)
+
+	testRedirect(e, alias, newURL)
+
+	e.PATCH("/url/"+alias).
+		WithJSON(storage.UpdateRequest{URL: "not a url"}).
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusUnprocessableEntity).
+		JSON().Object().
+		Value("code").String().IsEqual("invalid_url")
+
+	e.PATCH("/url/"+random.GenerateRandomString(12)).
+		WithJSON(storage.UpdateRequest{URL: newURL}).
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusNotFound)
+}
+
+func TestURLShortener_Batch(t *testing.T) {
+	e := he.WithConfig(he.Config{
+		BaseURL:  baseAddr,
+		Reporter: he.NewAssertReporter(t),
+		Client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	})
+
+	alias := random.GenerateRandomString(10)
+	urlA, urlB := gofakeit.URL(), gofakeit.URL()
+
+	results := e.POST("/url/batch").
+		WithJSON([]storage.Request{
+			{URL: urlA, Alias: alias},
+			{URL: urlB},
+			{URL: "not a url"},
+		}).
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusOK).
+		JSON().Object().
+		Value("results").Array()
+
+	results.Length().IsEqual(3)
+	results.Value(0).Object().Value("alias").String().IsEqual(alias)
+	generated := results.Value(1).Object().Value("alias").String().NotEmpty().Raw()
+	results.Value(2).Object().Value("status").String().IsEqual("Error")
+
+	testRedirect(e, alias, urlA)
+	testRedirect(e, generated, urlB)
+
+	ndjson := fmt.Sprintf("{\"url\": %q}\n{\"url\": %q}\n", gofakeit.URL(), gofakeit.URL())
+	e.POST("/url/batch").
+		WithHeader("Content-Type", "application/x-ndjson").
+		WithText(ndjson).
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusOK).
+		Body().Contains(`"index":1`)
+
+	results = e.DELETE("/url/batch").
+		WithJSON(map[string][]string{"aliases": {alias, generated, random.GenerateRandomString(12)}}).
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusOK).
+		JSON().Object().
+		Value("results").Array()
+
+	results.Value(0).Object().Value("status").String().IsEqual("OK")
+	results.Value(1).Object().Value("status").String().IsEqual("OK")
+	results.Value(2).Object().Value("error").String().IsEqual("URL not found")
+
+	testRedirectNotFound(e, alias)
+	testRedirectNotFound(e, generated)
+}
+
+func TestURLShortener_Client(t *testing.T) {
+	ctx := context.Background()
+	c := client.New(baseAddr, client.WithBasicAuth("admin", "password123"))
+
+	alias := random.GenerateRandomString(10)
+	urlA, urlB := gofakeit.URL(), gofakeit.URL()
+
+	saved, err := c.Shorten(ctx, client.ShortenRequest{URL: urlA, Alias: alias})
+	if err != nil {
+		t.Fatalf("Shorten: %v", err)
+	}
+	if saved != alias {
+		t.Fatalf("Shorten: got alias %q, want %q", saved, alias)
+	}
+
+	resolved, err := c.Resolve(ctx, alias)
+	if err != nil || resolved != urlA {
+		t.Fatalf("Resolve: got %q, %v, want %q", resolved, err, urlA)
+	}
+
+	if err := c.Update(ctx, alias, urlB); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if resolved, _ := c.Resolve(ctx, alias); resolved != urlB {
+		t.Fatalf("Resolve after Update: got %q, want %q", resolved, urlB)
+	}
+
+	if _, err := c.Shorten(ctx, client.ShortenRequest{URL: "not a url"}); !client.IsCode(err, client.CodeInvalidURL) {
+		t.Fatalf("Shorten invalid url: got %v, want %s", err, client.CodeInvalidURL)
+	}
+
+	if err := c.Delete(ctx, alias); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := c.Resolve(ctx, alias); !client.IsCode(err, client.CodeNotFound) {
+		t.Fatalf("Resolve after Delete: got %v, want %s", err, client.CodeNotFound)
+	}
+	if err := client.New(baseAddr).Delete(ctx, alias); !client.IsCode(err, client.CodeUnauthorized) {
+		t.Fatalf("Delete without credentials: got %v, want %s", err, client.CodeUnauthorized)
+	}
+}
+
+func TestURLShortener_Probes(t *testing.T) {
+	e := he.Default(t, baseAddr)
+
+	e.GET("/healthz").Expect().Status(http.StatusOK).
+		JSON().Object().Value("status").IsEqual("OK")
+	e.GET("/readyz").Expect().Status(http.StatusOK).
+		JSON().Object().Value("status").IsEqual("OK")
+
+	version := e.GET("/version").Expect().Status(http.StatusOK).JSON().Object()
+	version.Value("go_version").String().HasPrefix("go")
+	version.ContainsKey("version")
+}
+
+func TestURLShortener_Metrics(t *testing.T) {
+	e := he.WithConfig(he.Config{
+		BaseURL:  baseAddr,
+		Reporter: he.NewAssertReporter(t),
+		Client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	})
+
+	alias := random.GenerateRandomString(10)
+	urlToSave := gofakeit.URL()
+	e.POST("/url").
+		WithJSON(storage.Request{URL: urlToSave, Alias: alias}).
+		WithBasicAuth("admin", "password123").
+		Expect().Status(http.StatusOK)
+	testRedirect(e, alias, urlToSave)
+	testRedirect(e, alias, urlToSave) //from the cache
+	testRedirectNotFound(e, random.GenerateRandomString(12))
+
+	body := e.GET("/metrics").Expect().Status(http.StatusOK).Body()
+	body.Contains(`url_shortener_http_requests_total{method="POST",route="/url",status="200"}`)
+	body.Contains(`url_shortener_redirects_total{result="hit"}`)
+	body.Contains(`url_shortener_redirects_total{result="miss"}`)
+	body.Contains(`url_shortener_storage_operation_duration_seconds_count{op="storage.sqlite.GetURLByAlias",result="ok"}`)
+	body.Contains(`url_shortener_cache_lookups_total{result="hit",tier="memory"}`)
+	body.Contains(`url_shortener_cache_lookups_total{result="miss",tier="memory"}`)
+}